@@ -0,0 +1,222 @@
+package gostore
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// AggregateOp selects how field values are combined within a group.
+type AggregateOp int
+
+const (
+	// AggregateCount counts the documents in each group; the aggregated field is ignored.
+	AggregateCount AggregateOp = iota
+	// AggregateSum sums the numeric values of the aggregated field.
+	AggregateSum
+	// AggregateAvg averages the numeric values of the aggregated field.
+	AggregateAvg
+	// AggregateMin takes the smallest numeric value of the aggregated field.
+	AggregateMin
+	// AggregateMax takes the largest numeric value of the aggregated field.
+	AggregateMax
+)
+
+// aggregateState accumulates the values seen for a single group. Sums over
+// purely integral values are kept in int64 so they stay exact; the state falls
+// back to float64 on the first fractional value or on int64 overflow.
+type aggregateState struct {
+	count    int
+	integral bool // Whether intSum still holds the exact sum
+	intSum   int64
+	sum      float64
+	min      float64
+	max      float64
+}
+
+// add folds a numeric value into the state.
+func (as *aggregateState) add(value any) {
+	f := toFloat64(value)
+	if as.count == 0 {
+		as.integral = true
+		as.min, as.max = f, f
+	}
+	as.min = min(as.min, f)
+	as.max = max(as.max, f)
+	as.count++
+
+	if as.integral {
+		if i, ok := toInt64(value); ok {
+			if sum, ok := addInt64(as.intSum, i); ok {
+				as.intSum = sum
+				return
+			}
+		}
+		// Switch to floating point, carrying over the exact sum so far
+		as.integral = false
+		as.sum = float64(as.intSum)
+	}
+	as.sum += f
+}
+
+// total returns the sum of all values added so far.
+func (as *aggregateState) total() float64 {
+	if as.integral {
+		return float64(as.intSum)
+	}
+	return as.sum
+}
+
+// result reports the aggregate for the given operation.
+func (as *aggregateState) result(op AggregateOp) float64 {
+	switch op {
+	case AggregateCount:
+		return float64(as.count)
+	case AggregateSum:
+		return as.total()
+	case AggregateAvg:
+		return as.total() / float64(as.count)
+	case AggregateMin:
+		return as.min
+	default:
+		return as.max
+	}
+}
+
+// GroupSum is one group's total from GroupBySum.
+type GroupSum struct {
+	Int      int64   // Exact sum, valid while Integral is set
+	Float    float64 // Sum as a float64, rounded when Int exceeds 2^53
+	Integral bool    // Whether every value was an integer and Int did not overflow
+}
+
+// addInt64 adds two integers, reporting false if the result would overflow.
+func addInt64(a, b int64) (int64, bool) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, false
+	}
+	return sum, true
+}
+
+// GroupBy buckets all documents by the value of groupField and aggregates
+// aggField within each bucket.
+//
+// Documents missing groupField are skipped. For every operation other than
+// AggregateCount, documents whose aggField is missing or not numeric are also
+// skipped, and a group only appears in the result once it has received at
+// least one numeric value. Group values that are not comparable (maps, slices)
+// cannot be used as map keys, so they are converted to their fmt "%v" string
+// form, which is stable because fmt prints map keys in sorted order. Results
+// are float64, so integral sums beyond 2^53 are rounded; GroupBySum reports
+// them exactly.
+func (s *Store) GroupBy(groupField string, aggField string, op AggregateOp) (map[any]float64, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	if op < AggregateCount || op > AggregateMax {
+		return nil, ErrInvalidAggregate
+	}
+
+	groups := s.aggregateGroups(groupField, aggField, op)
+	result := make(map[any]float64, len(groups))
+	for key, state := range groups {
+		result[key] = state.result(op)
+	}
+	return result, nil
+}
+
+// GroupBySum sums aggField within each group like GroupBy with AggregateSum,
+// but reports integral sums exactly: GroupBy returns float64, which cannot
+// represent every integer beyond 2^53, so large sums such as money in cents
+// would be rounded. Groups that saw a fractional value, or whose integer sum
+// overflowed int64, have Integral unset and only Float is meaningful.
+func (s *Store) GroupBySum(groupField string, aggField string) (map[any]GroupSum, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	groups := s.aggregateGroups(groupField, aggField, AggregateSum)
+	result := make(map[any]GroupSum, len(groups))
+	for key, state := range groups {
+		sum := GroupSum{Float: state.total(), Integral: state.integral}
+		if state.integral {
+			sum.Int = state.intSum
+		}
+		result[key] = sum
+	}
+	return result, nil
+}
+
+// aggregateGroups buckets the live documents by groupField and accumulates
+// aggField for op within each bucket, skipping documents as GroupBy
+// describes.
+func (s *Store) aggregateGroups(groupField string, aggField string, op AggregateOp) map[any]*aggregateState {
+	groups := make(map[any]*aggregateState)
+	for _, doc := range s.liveDocuments() {
+		groupValue, exists := doc.data[groupField]
+		if !exists {
+			continue
+		}
+
+		value, exists := doc.data[aggField]
+		if op != AggregateCount && (!exists || !isNumber(value)) {
+			continue
+		}
+
+		key := groupKey(groupValue)
+		state, ok := groups[key]
+		if !ok {
+			state = &aggregateState{}
+			groups[key] = state
+		}
+
+		if op == AggregateCount {
+			state.count++
+		} else {
+			state.add(value)
+		}
+	}
+	return groups
+}
+
+// groupKey converts a group value into something usable as a map key.
+func groupKey(value any) any {
+	if value == nil || reflect.TypeOf(value).Comparable() {
+		return value
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// Fold combines every document into an accumulator in a single pass, for
+// custom aggregates such as histograms: fn receives the accumulator so far,
+// starting from init, and a document, and returns the new accumulator.
+// Documents are read one at a time in insertion order rather than loaded up
+// front, and each is copied once, so fn may keep or modify what it receives.
+// fn runs without the store locked and may call back into it; documents
+// deleted before they are reached are skipped. If the store is closed during
+// the fold, Fold stops with ErrStoreClosed.
+func (s *Store) Fold(init any, fn func(acc any, doc map[string]any) any) (any, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	acc := init
+	for _, handle := range s.snapshotHandles() {
+		if err := s.ensureOpen(); err != nil {
+			return nil, err
+		}
+		if handle.expired(time.Now()) {
+			continue
+		}
+
+		doc, exists := s.collection.Get(handle.index)
+		if !exists || doc.id != handle.id {
+			continue // Deleted or moved by Compact since the snapshot
+		}
+		acc = fn(acc, doc.data)
+	}
+
+	return acc, nil
+}
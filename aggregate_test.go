@@ -0,0 +1,185 @@
+package gostore
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestGroupBy tests grouping and aggregating documents by field.
+func TestGroupBy(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_, _ = s.Insert(map[string]any{"city": "NYC", "sales": 10})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "sales": 30.5})
+	_, _ = s.Insert(map[string]any{"city": "LA", "sales": 5})
+	_, _ = s.Insert(map[string]any{"city": "LA", "sales": "n/a"}) // Non-numeric
+	_, _ = s.Insert(map[string]any{"city": "SF"})                 // Missing aggregate field
+	_, _ = s.Insert(map[string]any{"sales": 100})                 // Missing group field
+
+	tests := []struct {
+		op       AggregateOp
+		expected map[any]float64
+	}{
+		{AggregateCount, map[any]float64{"NYC": 2, "LA": 2, "SF": 1}},
+		{AggregateSum, map[any]float64{"NYC": 40.5, "LA": 5}},
+		{AggregateAvg, map[any]float64{"NYC": 20.25, "LA": 5}},
+		{AggregateMin, map[any]float64{"NYC": 10, "LA": 5}},
+		{AggregateMax, map[any]float64{"NYC": 30.5, "LA": 5}},
+	}
+
+	for _, tt := range tests {
+		result, err := s.GroupBy("city", "sales", tt.op)
+		if err != nil {
+			t.Fatalf("GroupBy(%d) failed: %v", tt.op, err)
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("GroupBy(%d): expected %v, got %v", tt.op, tt.expected, result)
+		}
+	}
+
+	if _, err := s.GroupBy("city", "sales", AggregateOp(99)); err != ErrInvalidAggregate {
+		t.Errorf("Expected ErrInvalidAggregate, got %v", err)
+	}
+}
+
+// TestGroupByNonComparableKeys tests that map and slice group values are stringified.
+func TestGroupByNonComparableKeys(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_, _ = s.Insert(map[string]any{"tags": []any{"a", "b"}})
+	_, _ = s.Insert(map[string]any{"tags": []any{"a", "b"}})
+	_, _ = s.Insert(map[string]any{"tags": map[string]any{"y": 2, "x": 1}})
+
+	result, err := s.GroupBy("tags", "", AggregateCount)
+	if err != nil {
+		t.Fatalf("GroupBy failed: %v", err)
+	}
+
+	expected := map[any]float64{"[a b]": 2, "map[x:1 y:2]": 1}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+// TestGroupByIntegerSumPrecision tests that pure-integer sums are accumulated
+// exactly rather than drifting through float64 rounding.
+func TestGroupByIntegerSumPrecision(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	const big = int64(1) << 53 // Beyond this float64 can't represent every integer
+	_, _ = s.Insert(map[string]any{"account": "a", "cents": big})
+	_, _ = s.Insert(map[string]any{"account": "a", "cents": 1})
+	_, _ = s.Insert(map[string]any{"account": "a", "cents": int32(1)})
+
+	result, err := s.GroupBy("account", "cents", AggregateSum)
+	if err != nil {
+		t.Fatalf("GroupBy failed: %v", err)
+	}
+
+	// Summing in float64 would lose both increments: 2^53 + 1 rounds back to 2^53
+	if expected := float64(big + 2); result["a"] != expected {
+		t.Errorf("Expected exact sum %.0f, got %.0f", expected, result["a"])
+	}
+
+	// A fractional value switches the group to floating point
+	_, _ = s.Insert(map[string]any{"account": "b", "cents": 1})
+	_, _ = s.Insert(map[string]any{"account": "b", "cents": 0.5})
+	result, _ = s.GroupBy("account", "cents", AggregateSum)
+	if result["b"] != 1.5 {
+		t.Errorf("Expected mixed sum 1.5, got %v", result["b"])
+	}
+}
+
+// TestGroupBySum tests that integral sums beyond 2^53 are reported exactly
+// and that groups with fractional values fall back to floating point.
+func TestGroupBySum(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	const big = int64(1) << 53
+	_, _ = s.Insert(map[string]any{"account": "a", "cents": big})
+	_, _ = s.Insert(map[string]any{"account": "a", "cents": int32(1)})
+	_, _ = s.Insert(map[string]any{"account": "b", "cents": 1})
+	_, _ = s.Insert(map[string]any{"account": "b", "cents": 0.5})
+	_, _ = s.Insert(map[string]any{"account": "c", "cents": int64(math.MaxInt64)})
+	_, _ = s.Insert(map[string]any{"account": "c", "cents": 1})
+
+	sums, err := s.GroupBySum("account", "cents")
+	if err != nil {
+		t.Fatalf("GroupBySum failed: %v", err)
+	}
+
+	// 2^53 + 1 is the first integer float64 cannot represent
+	if sum := sums["a"]; !sum.Integral || sum.Int != big+1 {
+		t.Errorf("Expected exact sum %d, got %+v", big+1, sum)
+	}
+	if sums["a"].Float != float64(big) {
+		t.Errorf("Expected the float sum to round to 2^53, got %.0f", sums["a"].Float)
+	}
+	if sum := sums["b"]; sum.Integral || sum.Float != 1.5 {
+		t.Errorf("Expected mixed sum 1.5, got %+v", sum)
+	}
+	if sum := sums["c"]; sum.Integral || sum.Int != 0 {
+		t.Errorf("Expected an overflowing sum to fall back to float, got %+v", sum)
+	}
+}
+
+// TestAddInt64 tests overflow detection for integer sums.
+func TestAddInt64(t *testing.T) {
+	if sum, ok := addInt64(1<<62, 1<<62); ok {
+		t.Errorf("Expected overflow, got %d", sum)
+	}
+	if sum, ok := addInt64(-1<<62, -1<<62-1); ok {
+		t.Errorf("Expected negative overflow, got %d", sum)
+	}
+	if sum, ok := addInt64(40, 2); !ok || sum != 42 {
+		t.Errorf("Expected 42, got %d (ok=%v)", sum, ok)
+	}
+}
+
+// TestFold tests folding every document into an accumulator.
+func TestFold(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	for _, score := range []int{3, 1, 4, 1, 5} {
+		_, _ = s.Insert(map[string]any{"score": score})
+	}
+	gone, _ := s.Insert(map[string]any{"score": 100})
+	_ = s.Delete(gone)
+
+	sum, err := s.Fold(0, func(acc any, doc map[string]any) any {
+		return acc.(int) + doc["score"].(int)
+	})
+	if err != nil {
+		t.Fatalf("Fold failed: %v", err)
+	}
+	if sum != 14 {
+		t.Errorf("Expected sum 14, got %v", sum)
+	}
+
+	// Documents arrive in insertion order, and fn may call back into the store
+	order, _ := s.Fold([]any(nil), func(acc any, doc map[string]any) any {
+		_ = s.Count()
+		return append(acc.([]any), doc["score"])
+	})
+	if expected := []any{3, 1, 4, 1, 5}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected %v, got %v", expected, order)
+	}
+
+	// Closing the store mid-fold stops it
+	if _, err := s.Fold(0, func(acc any, doc map[string]any) any {
+		s.Close()
+		return acc
+	}); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+	if _, err := s.Fold(0, nil); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
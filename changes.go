@@ -0,0 +1,106 @@
+package gostore
+
+import (
+	"cmp"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// tombstoneRetention is the minimum number of recent deletes kept for
+// StreamChanges. Older tombstones are dropped in batches once twice as many
+// have accumulated.
+const tombstoneRetention = 10_000
+
+// tombstone records a deleted document for StreamChanges.
+type tombstone struct {
+	id      string
+	version uint64 // Version assigned to the delete
+}
+
+// recordTombstone assigns the deletion of docID a new version and retains it
+// for StreamChanges. The caller must hold s.mu for writing.
+func (s *Store) recordTombstone(docID string) {
+	version := atomic.AddUint64(&s.version, 1)
+	s.tombstones = append(s.tombstones, tombstone{id: docID, version: version})
+
+	if len(s.tombstones) >= 2*tombstoneRetention {
+		dropped := len(s.tombstones) - tombstoneRetention
+		s.tombstoneFloor = s.tombstones[dropped-1].version
+		s.tombstones = slices.Clone(s.tombstones[dropped:])
+	}
+}
+
+// StreamChanges streams every change made after fromVersion, in version
+// order, for incremental replication. Live documents written since then are
+// streamed with their current data; documents deleted since then, including
+// soft deletes, are streamed as markers with Deleted set and no data. A
+// document written several times appears once, at its latest version. Pass
+// the highest version received as fromVersion next time, or 0 for a full
+// sync. Deletes are retained for a limited window; if fromVersion is older
+// than the oldest retained delete, the stream fails with
+// ErrChangesUnavailable and the replica needs a full sync. Restore gives a
+// document a new version, so a restored document is streamed again after its
+// delete marker.
+func (s *Store) StreamChanges(fromVersion uint64, bufferSize int) *DocumentStream {
+	ds := NewDocumentStream(bufferSize)
+
+	if err := s.ensureOpen(); err != nil {
+		s.closeStreamWithError(ds, err)
+		return ds
+	}
+
+	s.mu.RLock()
+	if fromVersion > 0 && fromVersion < s.tombstoneFloor {
+		s.mu.RUnlock()
+		s.closeStreamWithError(ds, ErrChangesUnavailable)
+		return ds
+	}
+
+	var changes []DocumentResult
+	now := time.Now()
+	for _, doc := range s.collection.GetAllValid() {
+		if doc.version <= fromVersion {
+			continue
+		}
+		if entry, exists := s.handles[doc.id]; !exists || entry.handle.expired(now) {
+			continue
+		}
+		changes = append(changes, DocumentResult{
+			ID:        doc.id,
+			Data:      doc.data,
+			Version:   doc.version,
+			CreatedAt: doc.createdAt,
+			UpdatedAt: doc.updatedAt,
+		})
+	}
+
+	start, _ := slices.BinarySearchFunc(s.tombstones, fromVersion+1, func(t tombstone, version uint64) int {
+		return cmp.Compare(t.version, version)
+	})
+	for _, t := range s.tombstones[start:] {
+		changes = append(changes, DocumentResult{ID: t.id, Version: t.version, Deleted: true})
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(changes, func(a, b DocumentResult) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	go s.streamResults(ds, changes)
+	return ds
+}
+
+// streamResults sends prepared results to the stream until it is closed.
+func (s *Store) streamResults(ds *DocumentStream, results []DocumentResult) {
+	defer close(ds.results)
+	defer close(ds.errors)
+
+	for _, result := range results {
+		select {
+		case ds.results <- result:
+		case <-ds.ctx.Done():
+			return
+		}
+	}
+}
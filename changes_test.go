@@ -0,0 +1,172 @@
+package gostore
+
+import (
+	"errors"
+	"testing"
+)
+
+// collectChanges drains a change stream.
+func collectChanges(t *testing.T, stream *DocumentStream) []DocumentResult {
+	t.Helper()
+	defer stream.Close()
+
+	var changes []DocumentResult
+	for {
+		change, err := stream.Next()
+		if errors.Is(err, ErrStreamClosed) {
+			return changes
+		}
+		if err != nil {
+			t.Fatalf("Error reading changes: %v", err)
+		}
+		changes = append(changes, change)
+	}
+}
+
+// TestStreamChanges tests that changes since a version, including deletes,
+// are streamed in version order.
+func TestStreamChanges(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	keptID, _ := s.Insert(map[string]any{"n": 1})
+	deletedID, _ := s.Insert(map[string]any{"n": 2})
+	checkpoint := s.Version()
+
+	updatedID, _ := s.Insert(map[string]any{"n": 3})
+	_ = s.Update(updatedID, map[string]any{"n": 30})
+	_ = s.Delete(deletedID)
+	trashedID, _ := s.Insert(map[string]any{"n": 4})
+	_ = s.SoftDelete(trashedID)
+
+	changes := collectChanges(t, s.StreamChanges(checkpoint, 2))
+
+	type change struct {
+		id      string
+		deleted bool
+	}
+	want := []change{{updatedID, false}, {deletedID, true}, {trashedID, true}}
+	var got []change
+	for i, c := range changes {
+		got = append(got, change{c.ID, c.Deleted})
+		if i > 0 && c.Version <= changes[i-1].Version {
+			t.Errorf("Expected increasing versions, got %d after %d", c.Version, changes[i-1].Version)
+		}
+		if c.Deleted && c.Data != nil {
+			t.Errorf("Expected delete markers to carry no data, got %v", c.Data)
+		}
+	}
+	slicesEqual := len(got) == len(want)
+	for i := range want {
+		if !slicesEqual || got[i] != want[i] {
+			slicesEqual = false
+			break
+		}
+	}
+	if !slicesEqual {
+		t.Fatalf("Expected changes %v, got %v", want, got)
+	}
+	if changes[0].Data["n"] != 30 {
+		t.Errorf("Expected the latest data for the updated document, got %v", changes[0].Data)
+	}
+
+	// A full sync streams only live documents
+	full := collectChanges(t, s.StreamChanges(0, 0))
+	if len(full) != 4 {
+		t.Fatalf("Expected 2 live documents and 2 deletes, got %d changes", len(full))
+	}
+	if full[0].ID != keptID {
+		t.Errorf("Expected the oldest write first, got %s", full[0].ID)
+	}
+
+	// Nothing has changed since the current version
+	if changes := collectChanges(t, s.StreamChanges(s.Version(), 0)); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+}
+
+// TestStreamChangesRetention tests that a version older than the retained
+// deletes is reported rather than silently missing changes.
+func TestStreamChangesRetention(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	id, _ := s.Insert(map[string]any{"n": 0})
+	checkpoint := s.Version()
+	_ = s.Delete(id)
+
+	clone, _ := s.Clone()
+	defer clone.Close()
+
+	stream := clone.StreamChanges(checkpoint, 0)
+	if _, err := stream.Next(); !errors.Is(err, ErrChangesUnavailable) {
+		t.Errorf("Expected ErrChangesUnavailable from a clone, got %v", err)
+	}
+	if changes := collectChanges(t, clone.StreamChanges(0, 0)); len(changes) != 0 {
+		t.Errorf("Expected a full sync of an empty clone to succeed, got %v", changes)
+	}
+
+	for range 2 * tombstoneRetention {
+		id, _ := s.Insert(map[string]any{})
+		_ = s.Delete(id)
+	}
+	if len(s.tombstones) > 2*tombstoneRetention {
+		t.Errorf("Expected tombstones to be trimmed, got %d", len(s.tombstones))
+	}
+	if _, err := s.StreamChanges(checkpoint, 0).Next(); !errors.Is(err, ErrChangesUnavailable) {
+		t.Errorf("Expected ErrChangesUnavailable once tombstones are trimmed, got %v", err)
+	}
+
+	s.Close()
+	if _, err := s.StreamChanges(0, 0).Next(); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// TestStreamChangesRestore tests that a replica which applied a soft delete
+// gets the document back once it is restored.
+func TestStreamChangesRestore(t *testing.T) {
+	primary := NewStore()
+	defer primary.Close()
+	replica := NewStore()
+	defer replica.Close()
+
+	var synced uint64
+	replicate := func() {
+		for _, change := range collectChanges(t, primary.StreamChanges(synced, 0)) {
+			switch _, err := replica.Get(change.ID); {
+			case change.Deleted:
+				_ = replica.Delete(change.ID)
+			case err == nil:
+				_ = replica.Update(change.ID, change.Data)
+			default:
+				_ = replica.InsertWithID(change.ID, change.Data)
+			}
+			synced = change.Version
+		}
+	}
+
+	id, _ := primary.Insert(map[string]any{"name": "Alice"})
+	replicate()
+	if _, err := replica.Get(id); err != nil {
+		t.Fatalf("Expected document on the replica, got %v", err)
+	}
+
+	_ = primary.SoftDelete(id)
+	replicate()
+	if _, err := replica.Get(id); !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("Expected the soft delete to reach the replica, got %v", err)
+	}
+
+	if err := primary.Restore(id); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	replicate()
+	doc, err := replica.Get(id)
+	if err != nil {
+		t.Fatalf("Expected the restored document on the replica, got %v", err)
+	}
+	if doc.Data["name"] != "Alice" {
+		t.Errorf("Expected the restored data, got %v", doc.Data)
+	}
+}
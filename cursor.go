@@ -2,9 +2,12 @@
 package gostore
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"sort"
+	"time"
 )
 
 // DocumentLike constrains types that can be used as documents
@@ -17,6 +20,7 @@ type Cursor[T DocumentLike] interface {
 	Next() (*T, bool /* has next */, error)
 	Previous() (*T, bool /* has previous */, error)
 	Advance(count int /* can be negative */) (*T, bool /* has next/previous depending on intended direction */, error)
+	Seek(position int) (*T, bool /* has next */, error)
 	Reset() error     // For algorithms that need multiple passes
 	Clone() Cursor[T] // For nested operations
 	Count() int       // Maximum number of documents we can iterate over
@@ -25,11 +29,20 @@ type Cursor[T DocumentLike] interface {
 
 // StoreCursor provides bidirectional iteration over documents in the store
 type StoreCursor[T DocumentLike] struct {
-	store      *Store
-	collection *Collection
-	handles    []*DocumentHandle // Snapshot of document handles
-	position   int               // Current position in the handles slice
-	closed     bool              // Whether the cursor has been closed
+	store       *Store
+	collection  *Collection
+	handles     []*DocumentHandle         // Snapshot of document handles
+	position    int                       // Current position in the handles slice
+	closed      bool                      // Whether the cursor has been closed
+	skipDeleted bool                      // Whether to pass over deleted documents silently
+	filter      func(map[string]any) bool // Optional predicate documents must satisfy
+}
+
+// ReadOptions configures cursors created by ReadWithOptions.
+type ReadOptions struct {
+	// SkipDeleted makes the cursor silently pass over documents deleted after
+	// it was created instead of returning ErrDocumentDeleted for them.
+	SkipDeleted bool
 }
 
 // Next returns the next document and advances the cursor by one position
@@ -42,21 +55,47 @@ func (sc *StoreCursor[T]) Next() (*T, bool, error) {
 		return nil, false, nil
 	}
 
-	doc, err := sc.getDocumentAt(sc.position)
+	index, doc, err := sc.findDocument(sc.position, 1)
+	sc.position = min(index+1, len(sc.handles))
 	if err != nil {
-		sc.position++
 		return nil, false, err
 	}
+	if index >= len(sc.handles) {
+		return nil, false, nil // Only skipped documents remained
+	}
 
-	sc.position++
 	hasNext := sc.position < len(sc.handles)
 	typedDoc := T(doc)
 	return &typedDoc, hasNext, nil
 }
 
-// Next returns the next document and advances the cursor by one position
+// Previous moves the cursor back one position and returns the document there.
+// At the first position there is nothing before it, so Previous returns nil
+// without moving, mirroring Next at the end. The boolean reports whether a
+// further call to Previous would return a document, taking documents the
+// cursor skips into account.
 func (sc *StoreCursor[T]) Previous() (*T, bool, error) {
-	return sc.Advance(-1)
+	if sc.closed {
+		return nil, false, ErrStreamClosed
+	}
+
+	if sc.position <= 0 {
+		return nil, false, nil
+	}
+
+	index, doc, err := sc.findDocument(sc.position-1, -1)
+	sc.position = max(index, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	if index < 0 {
+		return nil, false, nil // Only skipped documents remained
+	}
+
+	before, _, _ := sc.findDocument(index-1, -1)
+	hasPrevious := before >= 0
+	typedDoc := T(doc)
+	return &typedDoc, hasPrevious, nil
 }
 
 // Advance moves the cursor by 'count' positions and returns the document at the new position.
@@ -79,17 +118,52 @@ func (sc *StoreCursor[T]) Advance(count int) (*T, bool, error) {
 		return nil, false, nil
 	}
 
+	step := 1
+	if count < 0 {
+		step = -1
+	}
+
 	sc.position = newPosition
-	doc, err := sc.getDocumentAt(sc.position)
+	index, doc, err := sc.findDocument(sc.position, step)
 	if err != nil {
 		return nil, false, err
 	}
+	if index < 0 || index >= len(sc.handles) {
+		return nil, false, nil // Only skipped documents remained in this direction
+	}
+	sc.position = index
 
 	hasMore := (count > 0 && sc.position < len(sc.handles)-1) || (count < 0 && sc.position > 0)
 	typedDoc := T(doc)
 	return &typedDoc, hasMore, nil
 }
 
+// Seek moves the cursor to an absolute position and returns the document there.
+// Like Advance, out-of-range positions are clamped to the first or last document.
+func (sc *StoreCursor[T]) Seek(position int) (*T, bool, error) {
+	if sc.closed {
+		return nil, false, ErrStreamClosed
+	}
+
+	if len(sc.handles) == 0 {
+		return nil, false, nil
+	}
+
+	sc.position = max(0, min(position, len(sc.handles)-1))
+	index, doc, err := sc.findDocument(sc.position, 1)
+	if err != nil {
+		return nil, false, err
+	}
+	if index >= len(sc.handles) {
+		return nil, false, nil // Only skipped documents remained
+	}
+	sc.position = index
+
+	hasNext := sc.position < len(sc.handles)-1
+	typedDoc := T(doc)
+	return &typedDoc, hasNext, nil
+}
+
 // Reset moves the cursor back to the beginning of the stream.
 func (sc *StoreCursor[T]) Reset() error {
 	if sc.closed {
@@ -108,15 +182,18 @@ func (sc *StoreCursor[T]) Clone() Cursor[T] {
 		}
 	}
 	return &StoreCursor[T]{
-		store:      sc.store,
-		collection: sc.collection,
-		handles:    sc.handles,
-		position:   sc.position,
-		closed:     false,
+		store:       sc.store,
+		collection:  sc.collection,
+		handles:     sc.handles,
+		position:    sc.position,
+		closed:      false,
+		skipDeleted: sc.skipDeleted,
+		filter:      sc.filter,
 	}
 }
 
 // Count returns the total number of documents in the cursor's snapshot.
+// For filtered cursors this counts every snapshotted document, matching or not.
 func (sc *StoreCursor[T]) Count() int {
 	if sc.closed || sc.handles == nil { // Handles case where handles slice is nilled out on close
 		return 0
@@ -124,6 +201,24 @@ func (sc *StoreCursor[T]) Count() int {
 	return len(sc.handles)
 }
 
+// MatchCount returns how many documents in the snapshot the cursor would
+// currently yield, applying its filter and skipping deleted documents. Unlike
+// Count it reads every document, so it costs a full pass over the snapshot.
+func (sc *StoreCursor[T]) MatchCount() int {
+	if sc.closed {
+		return 0
+	}
+
+	matches := 0
+	for index := range sc.handles {
+		doc, err := sc.getDocumentAt(index)
+		if err == nil && (sc.filter == nil || sc.filter(doc)) {
+			matches++
+		}
+	}
+	return matches
+}
+
 // Close releases any resources held by the cursor.
 func (sc *StoreCursor[T]) Close() error {
 	if sc.closed {
@@ -138,40 +233,133 @@ func (sc *StoreCursor[T]) Close() error {
 }
 
 // getDocumentAt retrieves the document at a specific index from the collection.
-// It handles cases where the document might have been deleted or doesn't exist.
+// It handles cases where the document might have been deleted or doesn't exist,
+// and reports ErrStoreClosed once the store has been closed, as its collection
+// is cleared on close.
 func (sc *StoreCursor[T]) getDocumentAt(index int) (map[string]any, error) {
+	if sc.store.closed.Load() {
+		return nil, ErrStoreClosed
+	}
+
 	if index < 0 || index >= len(sc.handles) {
 		return nil, fmt.Errorf("index out of bounds: %d", index)
 	}
 
 	handle := sc.handles[index]
+	if handle.expired(time.Now()) {
+		return nil, ErrDocumentDeleted // Expired since the snapshot, awaiting the sweeper
+	}
+
 	doc, ok := sc.collection.Get(handle.index)
-	if !ok {
+	if !ok || doc.id != handle.id { // The slot may have been reused by a later insert
 		return nil, ErrDocumentDeleted
 	}
 
 	return doc.data, nil
 }
 
+// findDocument walks from index in the direction of step until it reaches a
+// document the cursor should yield, returning its position and data. Deleted
+// documents are passed over when skipDeleted is set, and documents failing the
+// filter are always passed over. If the walk runs off either end, the returned
+// position is out of bounds and the data is nil.
+func (sc *StoreCursor[T]) findDocument(index, step int) (int, map[string]any, error) {
+	for ; index >= 0 && index < len(sc.handles); index += step {
+		doc, err := sc.getDocumentAt(index)
+		if sc.skipDeleted && errors.Is(err, ErrDocumentDeleted) {
+			continue
+		}
+		if err == nil && sc.filter != nil && !sc.filter(doc) {
+			continue
+		}
+		return index, doc, err
+	}
+	return index, nil, nil
+}
+
 // Read creates a cursor that iterates over all documents in the store
 func (s *Store) Read() (*StoreCursor[map[string]any], error) {
-	if s.closed.Load() {
-		return nil, ErrStoreClosed
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	return &StoreCursor[map[string]any]{
+		store:      s,
+		collection: s.collection,
+		handles:    s.snapshotHandles(),
+		position:   0,
+		closed:     false,
+	}, nil
+}
+
+// ReadWithOptions creates a cursor over all documents in the store, like
+// Read, configured by opts.
+func (s *Store) ReadWithOptions(opts ReadOptions) (*StoreCursor[map[string]any], error) {
+	cursor, err := s.Read()
+	if err != nil {
+		return nil, err
 	}
 
+	cursor.skipDeleted = opts.SkipDeleted
+	return cursor, nil
+}
+
+// ReadFilter creates a cursor over all documents in the store that only yields
+// those satisfying pred. Documents are tested as the cursor reaches them, so no
+// filtered copy of the store is built; pred receives a copy of each document.
+// Count still reports the size of the unfiltered snapshot; use MatchCount for
+// the number of matching documents.
+func (s *Store) ReadFilter(pred func(map[string]any) bool) (*StoreCursor[map[string]any], error) {
+	cursor, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	cursor.filter = pred
+	return cursor, nil
+}
+
+// ReadQuery creates a cursor over the documents in a range of an index, as
+// LookupRange would return them, in index order. The matching handles are
+// captured up front, so Count reports the number of matches.
+func (s *Store) ReadQuery(indexName string, minValues, maxValues []any) (*StoreCursor[map[string]any], error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	docIDs := index.lookupRange(minValues, maxValues)
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Capture snapshot of all document handles
-	handles := make([]*DocumentHandle, 0, len(s.handles))
+	handles := make([]*DocumentHandle, 0, len(docIDs))
+	now := time.Now()
+	for _, docID := range docIDs {
+		if handle, live := s.liveHandle(docID, now); live {
+			handles = append(handles, handle)
+		}
+	}
 
-	for _, entry := range s.handles {
-		handles = append(handles, entry.handle)
+	return &StoreCursor[map[string]any]{
+		store:      s,
+		collection: s.collection,
+		handles:    handles,
+		position:   0,
+		closed:     false,
+	}, nil
+}
+
+// ReadReverse creates a cursor that starts at the last document and moves
+// towards the first, for latest-first iteration. Next moves backward through
+// the store, Previous moves forward, and Reset returns to the last document.
+func (s *Store) ReadReverse() (*StoreCursor[map[string]any], error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
 	}
 
-	sort.Slice(handles, func(i, j int) bool {
-		return handles[i].index < handles[j].index
-	})
+	handles := s.snapshotHandles()
+	slices.Reverse(handles)
 
 	return &StoreCursor[map[string]any]{
 		store:      s,
@@ -182,27 +370,74 @@ func (s *Store) Read() (*StoreCursor[map[string]any], error) {
 	}, nil
 }
 
-// ReadIndex creates a cursor that iterates over documents in index order
-func (s *Store) ReadIndex(indexName string) (*StoreCursor[map[string]any], error) {
-	if s.closed.Load() {
-		return nil, ErrStoreClosed
+// snapshotHandles captures the handle of every document that has not expired,
+// in internal index order.
+func (s *Store) snapshotHandles() []*DocumentHandle {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	handles := make([]*DocumentHandle, 0, len(s.handles))
+
+	now := time.Now()
+	for _, entry := range s.handles {
+		if !entry.handle.expired(now) {
+			handles = append(handles, entry.handle)
+		}
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock() // Corrected: Changed Unlock to RUnlock
+	sort.Slice(handles, func(i, j int) bool {
+		return handles[i].index < handles[j].index
+	})
 
-	_, exists := s.indexes[indexName]
+	return handles
+}
+
+// ReadIndex creates a cursor that iterates over the documents in an index in
+// key order. Documents sharing a key are ordered by the optional tie fields,
+// compared like index keys with missing values first, and then by ID.
+func (s *Store) ReadIndex(indexName string, tieFields ...string) (*StoreCursor[map[string]any], error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
+	}
 
-	if !exists {
-		return nil, ErrIndexNotFound
+	return s.indexCursor(index.groups(), tieFields, false), nil
+}
+
+// ReadIndexReverse is like ReadIndex but iterates in descending key order,
+// for example to walk a leaderboard from the top score down. The order is
+// the exact reverse of ReadIndex, so documents sharing a key come last tie
+// first. The snapshot is already in descending order: Next moves towards
+// smaller keys and Previous, or a negative Advance, back towards larger ones.
+func (s *Store) ReadIndexReverse(indexName string, tieFields ...string) (*StoreCursor[map[string]any], error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Collect all document handles from the index in sorted order
+	return s.indexCursor(index.descendingGroups(), tieFields, true), nil
+}
+
+// indexCursor creates a cursor over the documents in groups, ordering each
+// group by tieFields and then by ID, reversed when descending is set.
+func (s *Store) indexCursor(groups []keyGroup, tieFields []string, descending bool) *StoreCursor[map[string]any] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	var handles []*DocumentHandle
+	now := time.Now()
+	for _, group := range groups {
+		if len(tieFields) > 0 && len(group.docIDs) > 1 {
+			s.sortByFields(group.docIDs, tieFields)
+		}
+		if descending {
+			slices.Reverse(group.docIDs)
+		}
 
-	for _, entry := range s.handles { // Assuming s.handles is already sorted by internal index
-		if slices.Contains(entry.indexes, indexName) {
-			handles = append(handles, entry.handle) // Found in this index, move to next document entry
+		for _, docID := range group.docIDs {
+			if handle, live := s.liveHandle(docID, now); live {
+				handles = append(handles, handle)
+			}
 		}
 	}
 
@@ -212,5 +447,27 @@ func (s *Store) ReadIndex(indexName string) (*StoreCursor[map[string]any], error
 		handles:    handles,
 		position:   0,
 		closed:     false,
-	}, nil
+	}
+}
+
+// sortByFields stably sorts docIDs by the values of fields in each document.
+// The caller must hold s.mu.
+func (s *Store) sortByFields(docIDs []string, fields []string) {
+	data := make(map[string]map[string]any, len(docIDs))
+	for _, docID := range docIDs {
+		if entry, exists := s.handles[docID]; exists {
+			if doc, exists := s.collection.Get(entry.handle.index); exists {
+				data[docID] = doc.data
+			}
+		}
+	}
+
+	slices.SortStableFunc(docIDs, func(a, b string) int {
+		for _, field := range fields {
+			if c := compareValues(data[a][field], data[b][field]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
 }
@@ -352,10 +352,8 @@ func TestStoreCursorReadIndex(t *testing.T) {
 	}
 	defer cursor.Close()
 
-	// Documents from ReadIndex are sorted by their internal collection index,
-	// which for sequential inserts often aligns with insertion order.
-	// We expect A1, B1, A2, C1 to be the order by handle index
-	expectedIDs := []string{idA1, idB1, idA2, idC1}
+	// Documents from ReadIndex come out in index key order
+	expectedIDs := []string{idA1, idA2, idB1, idC1}
 	receivedIDs := []string{}
 
 	for {
@@ -380,6 +378,9 @@ func TestStoreCursorReadIndex(t *testing.T) {
 	if len(receivedIDs) != len(expectedIDs) {
 		t.Errorf("Expected %d documents from index, got %d", len(expectedIDs), len(receivedIDs))
 	}
+	if expected := []string{"A", "A", "B", "C"}; !reflect.DeepEqual(receivedIDs, expected) {
+		t.Errorf("Expected groups in key order %v, got %v", expected, receivedIDs)
+	}
 
 	// Test ReadIndex with non-existent index
 	_, err = s.ReadIndex("non_existent")
@@ -471,3 +472,419 @@ func TestStoreCursorIntegrityAfterUpdate(t *testing.T) {
 		t.Errorf("Cursor did not reflect new field after update")
 	}
 }
+
+// TestStoreCursorReadReverse tests latest-first iteration.
+func TestStoreCursorReadReverse(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	for i := range 3 {
+		_, _ = s.Insert(map[string]any{"n": i})
+	}
+
+	cursor, err := s.ReadReverse()
+	if err != nil {
+		t.Fatalf("Failed to create reverse cursor: %v", err)
+	}
+	defer cursor.Close()
+
+	for _, expected := range []int{2, 1, 0} {
+		doc, hasNext, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+		if (*doc)["n"] != expected {
+			t.Errorf("Expected n=%d, got %v", expected, (*doc)["n"])
+		}
+		if hasNext != (expected > 0) {
+			t.Errorf("Expected hasNext=%v at n=%d", expected > 0, expected)
+		}
+	}
+
+	if doc, hasNext, err := cursor.Next(); doc != nil || hasNext || err != nil {
+		t.Errorf("Expected exhausted cursor, got %v, %v, %v", doc, hasNext, err)
+	}
+
+	// Previous moves back towards the end of the store
+	_, _, _ = cursor.Previous()
+	if doc, _, _ := cursor.Previous(); (*doc)["n"] != 1 {
+		t.Errorf("Expected Previous to return n=1, got %v", (*doc)["n"])
+	}
+
+	if err := cursor.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if doc, _, _ := cursor.Next(); (*doc)["n"] != 2 {
+		t.Errorf("Expected Reset to return to the last document, got %v", (*doc)["n"])
+	}
+}
+
+// TestStoreCursorSeek tests jumping to absolute positions.
+func TestStoreCursorSeek(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	for i := range 5 {
+		_, _ = s.Insert(map[string]any{"n": i})
+	}
+
+	cursor, err := s.Read()
+	if err != nil {
+		t.Fatalf("Failed to create cursor: %v", err)
+	}
+
+	tests := []struct {
+		position int
+		expected int
+		hasNext  bool
+	}{
+		{3, 3, true},
+		{0, 0, true},
+		{4, 4, false},
+		{100, 4, false}, // Clamped to the last document
+		{-7, 0, true},   // Clamped to the first document
+	}
+
+	for _, tt := range tests {
+		doc, hasNext, err := cursor.Seek(tt.position)
+		if err != nil {
+			t.Fatalf("Seek(%d) failed: %v", tt.position, err)
+		}
+		if (*doc)["n"] != tt.expected || hasNext != tt.hasNext {
+			t.Errorf("Seek(%d): expected n=%d hasNext=%v, got %v hasNext=%v",
+				tt.position, tt.expected, tt.hasNext, (*doc)["n"], hasNext)
+		}
+	}
+
+	_ = cursor.Close()
+	if _, _, err := cursor.Seek(0); !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("Expected ErrStreamClosed, got %v", err)
+	}
+}
+
+// TestStoreCursorSkipDeleted tests that a SkipDeleted cursor only yields live documents.
+func TestStoreCursorSkipDeleted(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	var ids []string
+	for i := range 5 {
+		id, _ := s.Insert(map[string]any{"n": i})
+		ids = append(ids, id)
+	}
+
+	cursor, err := s.ReadWithOptions(ReadOptions{SkipDeleted: true})
+	if err != nil {
+		t.Fatalf("Failed to create cursor: %v", err)
+	}
+	defer cursor.Close()
+
+	_ = s.Delete(ids[1])
+	_ = s.Delete(ids[4])
+	_, _ = s.Insert(map[string]any{"n": 99}) // Reuses a freed slot
+
+	var seen []any
+	for {
+		doc, _, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next() returned error: %v", err)
+		}
+		if doc == nil {
+			break
+		}
+		seen = append(seen, (*doc)["n"])
+	}
+
+	if expected := []any{0, 2, 3}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Expected %v, got %v", expected, seen)
+	}
+
+	// Moving backward skips deleted documents too
+	if doc, _, _ := cursor.Seek(3); (*doc)["n"] != 3 {
+		t.Fatalf("Expected n=3, got %v", (*doc)["n"])
+	}
+	if doc, _, err := cursor.Advance(-2); err != nil || (*doc)["n"] != 0 {
+		t.Errorf("Expected Advance(-2) to skip to n=0, got %v (err=%v)", doc, err)
+	}
+}
+
+// TestStoreCursorReadFilter tests that a filtered cursor only yields matching documents.
+func TestStoreCursorReadFilter(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	for i := range 6 {
+		_, _ = s.Insert(map[string]any{"n": i, "even": i%2 == 0})
+	}
+
+	cursor, err := s.ReadFilter(func(doc map[string]any) bool {
+		return doc["even"] == true
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cursor: %v", err)
+	}
+	defer cursor.Close()
+
+	if cursor.Count() != 6 {
+		t.Errorf("Expected Count to report the unfiltered snapshot, got %d", cursor.Count())
+	}
+	if cursor.MatchCount() != 3 {
+		t.Errorf("Expected MatchCount 3, got %d", cursor.MatchCount())
+	}
+
+	var seen []any
+	for {
+		doc, _, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next() returned error: %v", err)
+		}
+		if doc == nil {
+			break
+		}
+		seen = append(seen, (*doc)["n"])
+	}
+	if expected := []any{0, 2, 4}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Expected %v, got %v", expected, seen)
+	}
+
+	// Previous skips non-matching documents on the way back
+	_, _, _ = cursor.Previous()
+	if doc, _, _ := cursor.Previous(); (*doc)["n"] != 2 {
+		t.Errorf("Expected Previous to return n=2, got %v", (*doc)["n"])
+	}
+}
+
+// TestStoreCursorReadQuery tests a cursor over a range query's results.
+func TestStoreCursorReadQuery(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_score", []string{"score"})
+	for _, score := range []int{7, 2, 9, 4, 5, 1} {
+		_, _ = s.Insert(map[string]any{"score": score})
+	}
+
+	cursor, err := s.ReadQuery("by_score", []any{2}, []any{8})
+	if err != nil {
+		t.Fatalf("ReadQuery failed: %v", err)
+	}
+	defer cursor.Close()
+
+	if cursor.Count() != 4 {
+		t.Errorf("Expected Count to equal the 4 matches, got %d", cursor.Count())
+	}
+
+	var scores []any
+	for {
+		doc, _, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+		if doc == nil {
+			break
+		}
+		scores = append(scores, (*doc)["score"])
+	}
+	if expected := []any{2, 4, 5, 7}; !reflect.DeepEqual(scores, expected) {
+		t.Errorf("Expected %v in index order, got %v", expected, scores)
+	}
+
+	// The cursor pages backward as well
+	_, _, _ = cursor.Previous()
+	if doc, _, _ := cursor.Previous(); (*doc)["score"] != 5 {
+		t.Errorf("Expected Previous to return 5, got %v", (*doc)["score"])
+	}
+
+	if _, err := s.ReadQuery("missing", nil, nil); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestStoreCursorStoreClosed tests that a cursor reports ErrStoreClosed once
+// its store is closed mid-iteration.
+func TestStoreCursorStoreClosed(t *testing.T) {
+	s := NewStore()
+	for i := range 5 {
+		_, _ = s.Insert(map[string]any{"n": i})
+	}
+
+	cursor, err := s.ReadWithOptions(ReadOptions{SkipDeleted: true})
+	if err != nil {
+		t.Fatalf("Failed to create cursor: %v", err)
+	}
+	defer cursor.Close()
+
+	if _, _, err := cursor.Next(); err != nil {
+		t.Fatalf("Next() returned error before close: %v", err)
+	}
+
+	s.Close()
+
+	if _, _, err := cursor.Next(); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Next: expected ErrStoreClosed, got %v", err)
+	}
+	if _, _, err := cursor.Advance(-1); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Advance: expected ErrStoreClosed, got %v", err)
+	}
+	if _, _, err := cursor.Seek(0); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Seek: expected ErrStoreClosed, got %v", err)
+	}
+	if matches := cursor.MatchCount(); matches != 0 {
+		t.Errorf("Expected no matches after close, got %d", matches)
+	}
+}
+
+// TestStoreCursorReadIndexOrder tests that ReadIndex yields documents sorted
+// by index key, with tie fields ordering documents that share a key.
+func TestStoreCursorReadIndexOrder(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_score", []string{"score"})
+	for _, doc := range []map[string]any{
+		{"score": 30, "name": "c"},
+		{"score": 10, "name": "b"},
+		{"score": 20, "name": "x"},
+		{"score": 10, "name": "a"},
+		{"score": 10},
+		{"name": "unscored"},
+	} {
+		_, _ = s.Insert(doc)
+	}
+
+	read := func(tieFields ...string) [][2]any {
+		cursor, err := s.ReadIndex("by_score", tieFields...)
+		if err != nil {
+			t.Fatalf("ReadIndex failed: %v", err)
+		}
+		defer cursor.Close()
+
+		var got [][2]any
+		for {
+			doc, _, err := cursor.Next()
+			if err != nil {
+				t.Fatalf("Next() returned error: %v", err)
+			}
+			if doc == nil {
+				return got
+			}
+			got = append(got, [2]any{(*doc)["score"], (*doc)["name"]})
+		}
+	}
+
+	got := read()
+	if len(got) != 5 {
+		t.Fatalf("Expected the 5 indexed documents, got %v", got)
+	}
+	for i, expected := range []any{10, 10, 10, 20, 30} {
+		if got[i][0] != expected {
+			t.Errorf("Expected scores in key order, got %v", got)
+			break
+		}
+	}
+
+	expected := [][2]any{{10, nil}, {10, "a"}, {10, "b"}, {20, "x"}, {30, "c"}}
+	if got := read("name"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+// TestStoreCursorReadIndexReverse tests descending index cursors.
+func TestStoreCursorReadIndexReverse(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_score", []string{"score"})
+	for _, doc := range []map[string]any{
+		{"score": 10, "name": "b"},
+		{"score": 30, "name": "c"},
+		{"score": 20, "name": "x"},
+		{"score": 10, "name": "a"},
+		{"name": "unscored"},
+	} {
+		_, _ = s.Insert(doc)
+	}
+
+	cursor, err := s.ReadIndexReverse("by_score", "name")
+	if err != nil {
+		t.Fatalf("ReadIndexReverse failed: %v", err)
+	}
+	defer cursor.Close()
+
+	var got [][2]any
+	for {
+		doc, _, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next() returned error: %v", err)
+		}
+		if doc == nil {
+			break
+		}
+		got = append(got, [2]any{(*doc)["score"], (*doc)["name"]})
+	}
+	expected := [][2]any{{30, "c"}, {20, "x"}, {10, "b"}, {10, "a"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+
+	// Advance moves through the descending snapshot
+	_ = cursor.Reset()
+	if doc, _, err := cursor.Advance(1); err != nil || (*doc)["score"] != 20 {
+		t.Errorf("Expected the second highest score, got %v (%v)", doc, err)
+	}
+
+	if _, err := s.ReadIndexReverse("missing"); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestStoreCursorPrevious tests that Previous reports whether another previous
+// document exists after each call.
+func TestStoreCursorPrevious(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	for i := range 3 {
+		_, _ = s.Insert(map[string]any{"n": i})
+	}
+
+	cursor, _ := s.Read()
+	defer cursor.Close()
+
+	// Position 0: nothing precedes the first document
+	if doc, hasPrevious, err := cursor.Previous(); doc != nil || hasPrevious || err != nil {
+		t.Errorf("Expected nothing before the start, got %v, %v, %v", doc, hasPrevious, err)
+	}
+
+	// Position 1: moving back reaches the first document with none before it
+	_, _, _ = cursor.Next()
+	doc, hasPrevious, err := cursor.Previous()
+	if err != nil || doc == nil || (*doc)["n"] != 0 {
+		t.Fatalf("Expected n=0, got %v (%v)", doc, err)
+	}
+	if hasPrevious {
+		t.Error("Expected no previous document after reaching the first")
+	}
+
+	// Iterating backward from the end visits every document
+	_, _, _ = cursor.Seek(2)
+	var seen []any
+	for hasPrevious := true; hasPrevious; {
+		doc, hasPrevious, err = cursor.Previous()
+		if err != nil || doc == nil {
+			t.Fatalf("Expected a document, got %v (%v)", doc, err)
+		}
+		seen = append(seen, (*doc)["n"])
+	}
+	if expected := []any{1, 0}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Expected %v, got %v", expected, seen)
+	}
+
+	// Documents the cursor skips don't count as a previous document
+	filtered, _ := s.ReadFilter(func(doc map[string]any) bool { return doc["n"] != 0 })
+	defer filtered.Close()
+	_, _, _ = filtered.Seek(2)
+	if doc, hasPrevious, _ := filtered.Previous(); doc == nil || (*doc)["n"] != 1 || hasPrevious {
+		t.Errorf("Expected n=1 with no previous match, got %v, %v", doc, hasPrevious)
+	}
+}
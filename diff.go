@@ -0,0 +1,78 @@
+package gostore
+
+import (
+	"slices"
+	"time"
+)
+
+// Diff compares s against other by document ID and version, without looking
+// at document data. IDs only in s are reported as added, IDs in both with
+// different versions as updated, and IDs only in other as removed, each sorted.
+// It answers "what changed going from other to s", so other is typically an
+// older clone of s.
+func (s *Store) Diff(other *Store) (added, updated, removed []string, err error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := other.ensureOpen(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	current := s.documentVersions()
+	previous := other.documentVersions()
+
+	for docID, version := range current {
+		previousVersion, exists := previous[docID]
+		switch {
+		case !exists:
+			added = append(added, docID)
+		case previousVersion != version:
+			updated = append(updated, docID)
+		}
+	}
+
+	for docID := range previous {
+		if _, exists := current[docID]; !exists {
+			removed = append(removed, docID)
+		}
+	}
+
+	slices.Sort(added)
+	slices.Sort(updated)
+	slices.Sort(removed)
+	return added, updated, removed, nil
+}
+
+// documentVersions maps the ID of every live document to its version.
+func (s *Store) documentVersions() map[string]uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	versions := make(map[string]uint64, len(s.handles))
+	for docID, entry := range s.handles {
+		if entry.handle.expired(now) {
+			continue
+		}
+		if version, exists := s.collection.version(entry.handle.index); exists {
+			versions[docID] = version
+		}
+	}
+	return versions
+}
+
+// version returns the version of the document at index without copying it.
+func (c *Collection) version(index int) (uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if index < 0 || index >= len(c.documents) {
+		return 0, false
+	}
+
+	doc := c.documents[index]
+	if doc == nil || doc.deleted || doc.trashed {
+		return 0, false
+	}
+	return doc.version, true
+}
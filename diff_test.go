@@ -0,0 +1,56 @@
+package gostore
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDiff tests computing the changes between a store and an earlier clone.
+func TestDiff(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	unchanged, _ := s.Insert(map[string]any{"n": 1})
+	changed, _ := s.Insert(map[string]any{"n": 2})
+	deleted, _ := s.Insert(map[string]any{"n": 3})
+
+	before, _ := s.Clone()
+	defer before.Close()
+
+	_ = s.Update(changed, map[string]any{"n": 20})
+	_ = s.Delete(deleted)
+	inserted, _ := s.Insert(map[string]any{"n": 4})
+
+	added, updated, removed, err := s.Diff(before)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(added, []string{inserted}) {
+		t.Errorf("Expected added [%s], got %v", inserted, added)
+	}
+	if !reflect.DeepEqual(updated, []string{changed}) {
+		t.Errorf("Expected updated [%s], got %v", changed, updated)
+	}
+	if !reflect.DeepEqual(removed, []string{deleted}) {
+		t.Errorf("Expected removed [%s], got %v", deleted, removed)
+	}
+	for _, ids := range [][]string{added, updated, removed} {
+		for _, id := range ids {
+			if id == unchanged {
+				t.Errorf("Unchanged document %s reported as changed", id)
+			}
+		}
+	}
+
+	// Diffing the other way swaps added and removed
+	added, _, removed, _ = before.Diff(s)
+	if !reflect.DeepEqual(added, []string{deleted}) || !reflect.DeepEqual(removed, []string{inserted}) {
+		t.Errorf("Expected reversed diff, got added=%v removed=%v", added, removed)
+	}
+
+	before.Close()
+	if _, _, _, err := s.Diff(before); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package gostore
+
+import (
+	"container/list"
+	"slices"
+	"sync"
+)
+
+// EvictionPolicy selects which documents are removed when a store with a
+// document cap grows past it.
+type EvictionPolicy int
+
+const (
+	// EvictionNone disables eviction.
+	EvictionNone EvictionPolicy = iota
+	// EvictionLRU evicts the least recently used document first. Get, GetMany
+	// and the lookup methods count as uses, as do inserts.
+	EvictionLRU
+)
+
+// lruTracker records document access order. It has its own lock so reads
+// holding only the store's read lock can still record accesses.
+type lruTracker struct {
+	mu    sync.Mutex
+	order *list.List // Front is the most recently used document ID
+	items map[string]*list.Element
+}
+
+// newLRUTracker creates an empty tracker.
+func newLRUTracker() *lruTracker {
+	return &lruTracker{
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// add records a new document as the most recently used.
+func (t *lruTracker) add(docID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, exists := t.items[docID]; exists {
+		t.order.MoveToFront(elem)
+		return
+	}
+	t.items[docID] = t.order.PushFront(docID)
+}
+
+// touch marks a tracked document as the most recently used. Untracked IDs are
+// ignored, so a read racing with a delete cannot resurrect the entry.
+func (t *lruTracker) touch(docID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, exists := t.items[docID]; exists {
+		t.order.MoveToFront(elem)
+	}
+}
+
+// remove stops tracking a document.
+func (t *lruTracker) remove(docID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, exists := t.items[docID]; exists {
+		t.order.Remove(elem)
+		delete(t.items, docID)
+	}
+}
+
+// oldest returns the least recently used document ID.
+func (t *lruTracker) oldest() (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elem := t.order.Back()
+	if elem == nil {
+		return "", false
+	}
+	return elem.Value.(string), true
+}
+
+// SetMaxDocuments caps the number of live documents. When an insert takes the
+// store over n documents, documents are evicted according to policy until it
+// is back at the cap; evicted documents are deleted from the collection and
+// every index. Setting the cap below the current count evicts immediately.
+// Access history starts fresh, with existing documents ordered by ID, which
+// follows insertion order for generated IDs. n <= 0 or EvictionNone disables
+// eviction.
+func (s *Store) SetMaxDocuments(n int, policy EvictionPolicy) {
+	if s.ensureOpen() != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n <= 0 || policy == EvictionNone {
+		s.maxDocuments = 0
+		s.lru.Store(nil)
+		return
+	}
+
+	ids := make([]string, 0, len(s.handles))
+	for docID := range s.handles {
+		ids = append(ids, docID)
+	}
+	slices.Sort(ids)
+
+	s.maxDocuments = n
+	lru := newLRUTracker()
+	for _, docID := range ids {
+		lru.add(docID)
+	}
+	s.lru.Store(lru)
+
+	s.evictOverflow()
+}
+
+// Evictions returns how many documents have been evicted to honor the
+// document cap since the store was created.
+func (s *Store) Evictions() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.evictions.Load()
+}
+
+// trackInsert records a newly inserted document for eviction. The caller must
+// hold s.mu for writing.
+func (s *Store) trackInsert(docID string) {
+	if lru := s.lru.Load(); lru != nil {
+		lru.add(docID)
+	}
+}
+
+// trackRemove stops tracking a removed document. The caller must hold s.mu
+// for writing.
+func (s *Store) trackRemove(docID string) {
+	if lru := s.lru.Load(); lru != nil {
+		lru.remove(docID)
+	}
+}
+
+// evictOverflow deletes least recently used documents until the store is
+// within its document cap. The caller must hold s.mu for writing.
+func (s *Store) evictOverflow() {
+	s.makeRoom(0)
+}
+
+// makeRoom deletes least recently used documents until n more fit within the
+// document cap. The caller must hold s.mu for writing.
+func (s *Store) makeRoom(n int) {
+	lru := s.lru.Load()
+	for lru != nil && s.collection.Count()+n > s.maxDocuments {
+		victim, ok := lru.oldest()
+		if !ok {
+			return
+		}
+
+		if err := s.deleteLocked(victim); err != nil {
+			lru.remove(victim) // Stale entry; drop it and keep going
+			continue
+		}
+		s.evictions.Add(1)
+	}
+}
@@ -0,0 +1,115 @@
+package gostore
+
+import (
+	"testing"
+)
+
+// TestLRUEviction tests that the least recently used document is evicted
+// when an insert exceeds the cap.
+func TestLRUEviction(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_n", []string{"n"})
+	s.SetMaxDocuments(3, EvictionLRU)
+
+	id1, _ := s.Insert(map[string]any{"n": 1})
+	id2, _ := s.Insert(map[string]any{"n": 2})
+	id3, _ := s.Insert(map[string]any{"n": 3})
+
+	// Use the oldest two so the third becomes least recently used
+	_, _ = s.Get(id1)
+	_, _ = s.Lookup("by_n", []any{2})
+
+	id4, _ := s.Insert(map[string]any{"n": 4})
+
+	if s.Count() != 3 {
+		t.Errorf("Expected count to stay at 3, got %d", s.Count())
+	}
+	if _, err := s.Get(id3); err != ErrDocumentNotFound {
+		t.Errorf("Expected least recently used document to be evicted, got %v", err)
+	}
+	if results, _ := s.Lookup("by_n", []any{3}); len(results) != 0 {
+		t.Errorf("Expected evicted document to leave the index, got %d", len(results))
+	}
+	for _, id := range []string{id1, id2, id4} {
+		if _, err := s.Get(id); err != nil {
+			t.Errorf("Expected %s to survive, got %v", id, err)
+		}
+	}
+	if s.Evictions() != 1 {
+		t.Errorf("Expected 1 eviction, got %d", s.Evictions())
+	}
+
+	// Batches are trimmed back to the cap as well
+	_, _ = s.InsertBatch([]map[string]any{{"n": 5}, {"n": 6}})
+	if s.Count() != 3 || s.Evictions() != 3 {
+		t.Errorf("Expected count 3 and 3 evictions, got %d and %d", s.Count(), s.Evictions())
+	}
+}
+
+// TestInsertBatchEviction tests that a batch makes room by evicting older
+// documents rather than its own, and that a batch over the cap is rejected.
+func TestInsertBatchEviction(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	s.SetMaxDocuments(3, EvictionLRU)
+	old, _ := s.Insert(map[string]any{"n": 0})
+
+	ids, err := s.InsertBatch([]map[string]any{{"n": 1}, {"n": 2}, {"n": 3}})
+	if err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	for _, id := range ids {
+		if _, err := s.Get(id); err != nil {
+			t.Errorf("Expected batch document %s to survive, got %v", id, err)
+		}
+	}
+	if _, err := s.Get(old); err != ErrDocumentNotFound {
+		t.Errorf("Expected the older document to be evicted, got %v", err)
+	}
+
+	if _, err := s.InsertBatch([]map[string]any{{"n": 4}, {"n": 5}, {"n": 6}, {"n": 7}}); err != ErrBatchTooLarge {
+		t.Errorf("Expected ErrBatchTooLarge, got %v", err)
+	}
+	if s.Count() != 3 || s.Evictions() != 1 {
+		t.Errorf("Expected a rejected batch to leave the store alone, got count %d and %d evictions", s.Count(), s.Evictions())
+	}
+}
+
+// TestSetMaxDocuments tests shrinking and disabling the cap.
+func TestSetMaxDocuments(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	var ids []string
+	for i := range 5 {
+		id, _ := s.Insert(map[string]any{"n": i})
+		ids = append(ids, id)
+	}
+
+	// Lowering the cap evicts immediately, oldest inserts first
+	s.SetMaxDocuments(2, EvictionLRU)
+	if s.Count() != 2 {
+		t.Fatalf("Expected count 2, got %d", s.Count())
+	}
+	if _, err := s.Get(ids[4]); err != nil {
+		t.Errorf("Expected newest document to survive, got %v", err)
+	}
+
+	// Deleted documents stop being eviction candidates
+	_ = s.Delete(ids[3])
+	_, _ = s.Insert(map[string]any{"n": 10})
+	if s.Evictions() != 3 {
+		t.Errorf("Expected no eviction after delete freed room, got %d", s.Evictions())
+	}
+
+	s.SetMaxDocuments(0, EvictionLRU)
+	for i := range 5 {
+		_, _ = s.Insert(map[string]any{"n": 20 + i})
+	}
+	if s.Count() != 7 {
+		t.Errorf("Expected eviction to be disabled, got count %d", s.Count())
+	}
+}
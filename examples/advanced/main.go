@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"sync"
-	"time"
 
 	store "github.com/asaidimu/go-store/v3"
 )
@@ -51,36 +50,36 @@ func main() {
 		fmt.Printf("Advanced: Warning: Expected version %d, got %d. (Async updates can cause non-sequential versions from a single goroutine's perspective, but total versions should be correct).\n", numConcurrentUpdates+1, finalDoc.Version)
 	}
 
-	// 2. Using a theorized Functional Index (if implemented as discussed)
-	// This part requires the CreateFunctionalIndex and Filter methods to be added to your store.go
-	// For demonstration, we'll assume they exist and run a small example.
-	fmt.Println("\nAdvanced: Demonstrating (theorized) Functional Index usage...")
+	// 2. Using a Functional Index
+	// The index key is derived from each document instead of a stored field.
+	fmt.Println("\nAdvanced: Demonstrating Functional Index usage...")
+	err = s.CreateFunctionalIndex("expensive_in_stock", func(doc map[string]any) (any, bool) {
+		price, priceOK := doc["price"].(int)
+		inStock, stockOK := doc["in_stock"].(bool)
+		if !priceOK || !stockOK {
+			return nil, false // Not a product; leave it out of the index
+		}
+		return price > 100 && inStock, true
+	})
+	if err != nil {
+		log.Fatalf("Advanced: Failed to create functional index: %v", err)
+	}
+
 	s.Insert(map[string]any{"product": "Laptop", "price": 1200, "in_stock": true})
 	s.Insert(map[string]any{"product": "Mouse", "price": 25, "in_stock": true})
 	s.Insert(map[string]any{"product": "Monitor", "price": 300, "in_stock": false})
 	s.Insert(map[string]any{"product": "Keyboard", "price": 75, "in_stock": true})
 
-	time.Sleep(100 * time.Millisecond) // Allow updates to process
-
-
-	fmt.Println("Advanced: (Simulated) Expensive In-Stock Items (price > 100 and in_stock == true):")
-	streamAll := s.Stream(10)
-	filteredCount := 0
-	for {
-		docRes, err := streamAll.Next()
-		if err != nil {
-			break // Stream closed or error
-		}
-		price, priceOK := docRes.Data["price"].(int)
-		inStock, stockOK := docRes.Data["in_stock"].(bool)
-		if priceOK && stockOK && price > 100 && inStock {
-			fmt.Printf("  Product: %s, Price: %d\n", docRes.Data["product"], price)
-			filteredCount++
-		}
+	fmt.Println("Advanced: Expensive In-Stock Items (price > 100 and in_stock == true):")
+	expensive, err := s.Lookup("expensive_in_stock", []any{true})
+	if err != nil {
+		log.Fatalf("Advanced: Failed to query functional index: %v", err)
+	}
+	for _, docRes := range expensive {
+		fmt.Printf("  Product: %s, Price: %d\n", docRes.Data["product"], docRes.Data["price"])
 	}
-	streamAll.Close()
-	if filteredCount == 0 {
-		fmt.Println("  No expensive in-stock items found (simulated, or if functional index not implemented).")
+	if len(expensive) == 0 {
+		fmt.Println("  No expensive in-stock items found.")
 	}
 
 	// 3. Dropping an Index
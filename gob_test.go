@@ -0,0 +1,226 @@
+package gostore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestEncodeDecode tests that documents, versions, timestamps, TTLs and index
+// definitions survive a gob round trip.
+func TestEncodeDecode(t *testing.T) {
+	src := NewStore()
+	defer src.Close()
+
+	_ = src.CreateIndexWithDegree("by_city", []string{"city"}, 8)
+	_ = src.CreatePartialIndex("active", []string{"city"}, func(doc map[string]any) bool { return doc["active"] == true })
+
+	nested := map[string]any{
+		"city":   "NYC",
+		"active": true,
+		"count":  42,
+		"score":  1.5,
+		"tags":   []any{"a", 2, map[string]any{"deep": []any{"x"}}},
+		"names":  []string{"p", "q"},
+		"owner":  map[string]any{"name": "Alice", "since": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		"absent": nil,
+	}
+	id, _ := src.Insert(nested)
+	_ = src.Update(id, nested) // Bump the version past 1
+	emptyID, _ := src.Insert(map[string]any{})
+	ttlID, _ := src.InsertWithTTL(map[string]any{"city": "LA"}, time.Hour)
+	deletedID, _ := src.Insert(map[string]any{"city": "SF"})
+	_ = src.Delete(deletedID)
+
+	var buf bytes.Buffer
+	if err := src.Encode(&buf); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	dst, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	defer dst.Close()
+
+	if dst.Count() != 3 {
+		t.Errorf("Expected 3 documents, got %d", dst.Count())
+	}
+
+	original, _ := src.Get(id)
+	decoded, err := dst.Get(id)
+	if err != nil {
+		t.Fatalf("Expected decoded document, got %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Data, original.Data) {
+		t.Errorf("Expected %v, got %v", original.Data, decoded.Data)
+	}
+	if decoded.Version != original.Version || !decoded.CreatedAt.Equal(original.CreatedAt) || !decoded.UpdatedAt.Equal(original.UpdatedAt) {
+		t.Errorf("Expected metadata %+v, got %+v", original, decoded)
+	}
+
+	if doc, err := dst.Get(emptyID); err != nil || doc.Data == nil || len(doc.Data) != 0 {
+		t.Errorf("Expected an empty document, got %v (%v)", doc, err)
+	}
+	srcExpiry := src.handles[ttlID].handle.expiry()
+	if entry, exists := dst.handles[ttlID]; !exists || !entry.handle.expiry().Equal(srcExpiry) {
+		t.Errorf("Expected TTL to survive with expiry %v", srcExpiry)
+	}
+	if _, exists := dst.expiring[ttlID]; !exists {
+		t.Error("Expected TTL document to be tracked for expiry")
+	}
+	if _, err := dst.Get(deletedID); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected deleted document to be absent, got %v", err)
+	}
+
+	if results, _ := dst.Lookup("by_city", []any{"NYC"}); len(results) != 1 || results[0].ID != id {
+		t.Errorf("Expected by_city to be rebuilt, got %v", results)
+	}
+	if dst.HasIndex("active") {
+		t.Error("Expected partial index to be skipped")
+	}
+
+	// The version counter continues rather than restarting
+	newID, _ := dst.Insert(map[string]any{"city": "NYC"})
+	if doc, _ := dst.Get(newID); doc.Version <= original.Version {
+		t.Errorf("Expected version after %d, got %d", original.Version, doc.Version)
+	}
+}
+
+// TestDecodeErrors tests that malformed input and closed stores are reported.
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("not gob"))); err == nil {
+		t.Error("Expected an error decoding garbage")
+	}
+
+	s := NewStore()
+	s.Close()
+	if err := s.Encode(&bytes.Buffer{}); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// persistenceBenchmarkSize is the number of documents in the persistence benchmarks.
+const persistenceBenchmarkSize = 100_000
+
+// newPersistenceBenchmarkStore builds a store for the persistence benchmarks.
+func newPersistenceBenchmarkStore(b *testing.B) *Store {
+	b.Helper()
+
+	s := NewStore()
+	docs := make([]map[string]any, persistenceBenchmarkSize)
+	for i := range docs {
+		docs[i] = map[string]any{
+			"name":  fmt.Sprintf("user-%d", i),
+			"age":   i % 90,
+			"score": float64(i) / 7,
+			"tags":  []any{"a", "b"},
+		}
+	}
+	if _, err := s.InsertBatch(docs); err != nil {
+		b.Fatal(err)
+	}
+	return s
+}
+
+// BenchmarkPersistence compares gob Encode/Decode with the NDJSON path.
+func BenchmarkPersistence(b *testing.B) {
+	s := newPersistenceBenchmarkStore(b)
+	defer s.Close()
+
+	var gobData, jsonData bytes.Buffer
+	_ = s.Encode(&gobData)
+	_, _ = s.ExportNDJSON(&jsonData)
+
+	b.Run("EncodeGob", func(b *testing.B) {
+		for b.Loop() {
+			if err := s.Encode(&bytes.Buffer{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ExportNDJSON", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := s.ExportNDJSON(&bytes.Buffer{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("DecodeGob", func(b *testing.B) {
+		for b.Loop() {
+			dst, err := Decode(bytes.NewReader(gobData.Bytes()))
+			if err != nil {
+				b.Fatal(err)
+			}
+			dst.Close()
+		}
+	})
+	b.Run("ImportNDJSON", func(b *testing.B) {
+		for b.Loop() {
+			dst := NewStore()
+			if _, err := dst.ImportNDJSON(bytes.NewReader(jsonData.Bytes())); err != nil {
+				b.Fatal(err)
+			}
+			dst.Close()
+		}
+	})
+}
+
+// TestEncodeDecodeTypedIndex tests that typed index definitions survive a
+// gob round trip.
+func TestEncodeDecodeTypedIndex(t *testing.T) {
+	src := NewStore()
+	defer src.Close()
+
+	_ = src.CreateStrictTypedIndex("by_age", []string{"age"}, []reflect.Kind{reflect.Int})
+	_, _ = src.Insert(map[string]any{"age": 30})
+
+	var buf bytes.Buffer
+	if err := src.Encode(&buf); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	dst, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	defer dst.Close()
+
+	if _, err := dst.Insert(map[string]any{"age": "31"}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected decoded index to stay strict, got %v", err)
+	}
+	if results, _ := dst.Lookup("by_age", []any{30}); len(results) != 1 {
+		t.Errorf("Expected decoded index to be populated, got %v", results)
+	}
+}
+
+// TestDecodeIndexDegree tests that every kind of decoded index keeps the
+// B-tree degree it was encoded with.
+func TestDecodeIndexDegree(t *testing.T) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(gobStore{
+		Indexes: []gobIndex{
+			{Name: "plain", Fields: []string{"city"}, Degree: 8},
+			{Name: "typed", Fields: []string{"age"}, Degree: 8, Kinds: []reflect.Kind{reflect.Int}, Strict: true},
+			{Name: "array", Fields: []string{"tags"}, Degree: 8, Array: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	s, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	defer s.Close()
+
+	for _, name := range []string{"plain", "typed", "array"} {
+		if degree := s.indexes[name].degree; degree != 8 {
+			t.Errorf("Expected %s index to keep degree 8, got %d", name, degree)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package gostore
+
+import "slices"
+
+// IndexAction identifies whether an IndexEvent added or removed a document.
+type IndexAction int
+
+const (
+	// IndexAdded reports a document filed under a key.
+	IndexAdded IndexAction = iota
+	// IndexRemoved reports a document taken out from under a key.
+	IndexRemoved
+)
+
+// String returns a readable name for the index action.
+func (ia IndexAction) String() string {
+	switch ia {
+	case IndexAdded:
+		return "added"
+	case IndexRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// IndexEvent describes a document entering or leaving an index key. An update
+// that moves a document to a new key reports IndexRemoved for the old key and
+// then IndexAdded for the new one; an update that leaves the key unchanged
+// reports nothing. Multi-key indexes report each element's key separately.
+type IndexEvent struct {
+	IndexName string
+	DocID     string
+	Key       []any
+	Action    IndexAction
+}
+
+// SetIndexHook installs fn to observe index membership changes, for example
+// to assert in tests that an update moved a document between keys. fn is
+// called synchronously for every change, including when CreateIndex files the
+// existing documents, but not when DropIndex or Truncate clear whole indexes.
+// It runs with the store locked, so it must not call back into the store.
+// Passing nil removes the hook, which is the default; without one, index
+// writes only pay for an atomic load.
+func (s *Store) SetIndexHook(fn func(event IndexEvent)) {
+	if s == nil {
+		return
+	}
+
+	if fn == nil {
+		s.indexHook.Store(nil)
+		return
+	}
+	s.indexHook.Store(&fn)
+}
+
+// notify reports a membership change to the store's index hook, if any.
+func (fi *fieldIndex) notify(docID string, keyValues []any, action IndexAction) {
+	if fi.hook == nil {
+		return
+	}
+	if fn := fi.hook.Load(); fn != nil {
+		(*fn)(IndexEvent{IndexName: fi.name, DocID: docID, Key: slices.Clone(keyValues), Action: action})
+	}
+}
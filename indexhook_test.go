@@ -0,0 +1,56 @@
+package gostore
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSetIndexHook tests that index membership changes are reported.
+func TestSetIndexHook(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	existing, _ := s.Insert(map[string]any{"score": 1})
+
+	var events []IndexEvent
+	s.SetIndexHook(func(event IndexEvent) {
+		events = append(events, event)
+	})
+	expect := func(want ...IndexEvent) {
+		t.Helper()
+		if !reflect.DeepEqual(events, want) {
+			t.Errorf("Expected events %v, got %v", want, events)
+		}
+		events = nil
+	}
+
+	// Creating an index files the existing documents
+	_ = s.CreateIndex("by_score", []string{"score"})
+	expect(IndexEvent{"by_score", existing, []any{1}, IndexAdded})
+
+	id, _ := s.Insert(map[string]any{"score": 5})
+	expect(IndexEvent{"by_score", id, []any{5}, IndexAdded})
+
+	// Moving a document between keys removes it from the old one first
+	_ = s.Update(id, map[string]any{"score": 7})
+	expect(
+		IndexEvent{"by_score", id, []any{5}, IndexRemoved},
+		IndexEvent{"by_score", id, []any{7}, IndexAdded},
+	)
+
+	// An update that keeps the key reports nothing
+	_ = s.Update(id, map[string]any{"score": 7, "name": "x"})
+	expect()
+
+	_ = s.Delete(id)
+	expect(IndexEvent{"by_score", id, []any{7}, IndexRemoved})
+
+	// Removing the hook stops reporting
+	s.SetIndexHook(nil)
+	_, _ = s.Insert(map[string]any{"score": 9})
+	expect()
+
+	if IndexAdded.String() != "added" || IndexRemoved.String() != "removed" {
+		t.Errorf("Unexpected action names %q and %q", IndexAdded, IndexRemoved)
+	}
+}
@@ -0,0 +1,83 @@
+package gostore
+
+import (
+	"cmp"
+	"context"
+)
+
+// Join pairs documents whose keys in leftIndex and rightIndex are equal and
+// calls emit for each pair that also satisfies on; a nil on accepts every
+// pair. Both indexes are already sorted, so they are merged in a single pass
+// instead of building a hash table, and keys match the way lookups on
+// leftIndex do, so an int key joins an equal float64 key. If either index
+// uses a custom comparator, both must order keys the same way. Pairs are
+// emitted in key order, then by left and right document ID, and each pair
+// gets its own copies of the documents, so on and emit may keep or modify
+// them. The index keys are read before the first call, so on and emit may
+// call back into the store. The error reports a missing index, a closed store
+// or a failure reading the documents, none of which emit could otherwise
+// tell apart from a join with no matches.
+func (s *Store) Join(leftIndex, rightIndex string, on func(left, right map[string]any) bool, emit func(left, right map[string]any)) error {
+	left, err := s.indexForQuery(context.Background(), leftIndex)
+	if err != nil {
+		return err
+	}
+
+	right, err := s.indexForQuery(context.Background(), rightIndex)
+	if err != nil {
+		return err
+	}
+
+	leftGroups, rightGroups := left.groups(), right.groups()
+	for i, j := 0, 0; i < len(leftGroups) && j < len(rightGroups); {
+		switch c := left.compareKeys(leftGroups[i].key, rightGroups[j].key); {
+		case c < 0:
+			i++
+		case c > 0:
+			j++
+		default:
+			if err := s.joinGroups(leftGroups[i].docIDs, rightGroups[j].docIDs, on, emit); err != nil {
+				return err
+			}
+			i++
+			j++
+		}
+	}
+
+	return nil
+}
+
+// joinGroups emits every matching pair from two groups of documents with
+// equal keys. Documents removed since the keys were read are skipped.
+func (s *Store) joinGroups(leftIDs, rightIDs []string, on func(left, right map[string]any) bool, emit func(left, right map[string]any)) error {
+	leftDocs, err := s.collectDocumentResults(context.Background(), leftIDs)
+	if err != nil {
+		return err
+	}
+
+	rightDocs, err := s.collectDocumentResults(context.Background(), rightIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range leftDocs {
+		for _, r := range rightDocs {
+			left, right := copyDocument(l.Data), copyDocument(r.Data)
+			if on == nil || on(left, right) {
+				emit(left, right)
+			}
+		}
+	}
+
+	return nil
+}
+
+// compareKeys orders two index keys the way the B-tree does.
+func compareKeys(a, b []any) int {
+	for i := range min(len(a), len(b)) {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
@@ -0,0 +1,74 @@
+package gostore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestJoin tests joining orders to customers on a shared key.
+func TestJoin(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("customer_id", []string{"customer_id"})
+	_ = s.CreateIndex("order_customer", []string{"order_customer"})
+
+	_, _ = s.Insert(map[string]any{"customer_id": 1, "name": "Alice"})
+	_, _ = s.Insert(map[string]any{"customer_id": 2, "name": "Bob"})
+	_, _ = s.Insert(map[string]any{"customer_id": 3, "name": "Carol"}) // No orders
+	_, _ = s.Insert(map[string]any{"order_customer": 1, "item": "book", "qty": 1})
+	_, _ = s.Insert(map[string]any{"order_customer": 1.0, "item": "pen", "qty": 5}) // Numeric keys match across types
+	_, _ = s.Insert(map[string]any{"order_customer": 2, "item": "lamp", "qty": 2})
+	_, _ = s.Insert(map[string]any{"order_customer": 4, "item": "desk", "qty": 1}) // No customer
+
+	var pairs [][2]any
+	err := s.Join("customer_id", "order_customer", nil, func(left, right map[string]any) {
+		pairs = append(pairs, [2]any{left["name"], right["item"]})
+	})
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+
+	expected := map[[2]any]bool{{"Alice", "book"}: true, {"Alice", "pen"}: true, {"Bob", "lamp"}: true}
+	got := make(map[[2]any]bool)
+	for _, pair := range pairs {
+		got[pair] = true
+	}
+	if len(pairs) != len(expected) || !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected pairs %v, got %v", expected, pairs)
+	}
+	if pairs[len(pairs)-1] != [2]any{"Bob", "lamp"} {
+		t.Errorf("Expected pairs in key order, got %v", pairs)
+	}
+
+	// The predicate narrows the matches
+	pairs = nil
+	_ = s.Join("customer_id", "order_customer", func(left, right map[string]any) bool {
+		return right["qty"].(int) > 1
+	}, func(left, right map[string]any) {
+		pairs = append(pairs, [2]any{left["name"], right["item"]})
+	})
+	if want := [][2]any{{"Alice", "pen"}, {"Bob", "lamp"}}; !reflect.DeepEqual(pairs, want) {
+		t.Errorf("Expected %v, got %v", want, pairs)
+	}
+
+	// Each pair gets its own documents; Alice is paired twice
+	shared := false
+	_ = s.Join("customer_id", "order_customer", nil, func(left, right map[string]any) {
+		if left["joined"] != nil || right["joined"] != nil {
+			shared = true
+		}
+		left["joined"], right["joined"] = true, true
+	})
+	if shared {
+		t.Error("Expected every pair to get its own copies of the documents")
+	}
+	if results, _ := s.Lookup("customer_id", []any{1}); results[0].Data["joined"] != nil {
+		t.Errorf("Join leaked a modification into the store: %v", results[0].Data)
+	}
+
+	if err := s.Join("missing", "order_customer", nil, func(_, _ map[string]any) {}); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package gostore
+
+import (
+	"reflect"
+	"time"
+	"unsafe"
+
+	"github.com/google/btree"
+)
+
+// Approximate sizes, in bytes, of the runtime structures behind stored values.
+const (
+	interfaceSize   = int64(unsafe.Sizeof(any(nil)))
+	stringSize      = int64(unsafe.Sizeof(""))
+	sliceSize       = int64(unsafe.Sizeof([]any(nil)))
+	pointerSize     = int64(unsafe.Sizeof(uintptr(0)))
+	mapHeaderSize   = 48 // runtime map header
+	mapEntryPadding = 8  // per-entry bookkeeping in map buckets
+)
+
+// MemoryStats is an approximate breakdown of the memory held by a store, in
+// bytes. The figures are estimates derived from the shape of the stored data,
+// not measurements, but they are consistent between calls and grow with the
+// data, which makes them suitable for eviction or sharding thresholds.
+type MemoryStats struct {
+	Documents int64 // Document data and collection slots, including soft-deleted documents
+	Handles   int64 // Document handles and index membership lists
+	Indexes   int64 // Index keys, document ID sets and B-tree nodes
+	Total     int64 // Sum of the above
+}
+
+// EstimateMemory walks the store's documents, handles and indexes and returns
+// an approximate account of the memory they use. It holds the read lock for
+// the duration of the walk. A closed store reports zero.
+func (s *Store) EstimateMemory() MemoryStats {
+	if s.ensureOpen() != nil {
+		return MemoryStats{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats MemoryStats
+	stats.Documents = s.collection.estimateSize()
+
+	handleSize := int64(unsafe.Sizeof(DocumentHandle{}) + unsafe.Sizeof(HandleEntry{}))
+	for docID, entry := range s.handles {
+		stats.Handles += estimateStringSize(docID) + pointerSize + handleSize + mapEntryPadding
+		stats.Handles += int64(len(entry.indexes)) * stringSize
+	}
+	for docID := range s.trash {
+		stats.Handles += estimateStringSize(docID) + pointerSize + handleSize + mapEntryPadding
+	}
+
+	for _, index := range s.indexes {
+		stats.Indexes += index.estimateSize()
+	}
+
+	stats.Total = stats.Documents + stats.Handles + stats.Indexes
+	return stats
+}
+
+// estimateSize approximates the memory held by the collection's documents.
+func (c *Collection) estimateSize() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	size := int64(cap(c.documents))*pointerSize + int64(cap(c.freeSlots))*8
+	documentSize := int64(unsafe.Sizeof(Document{}))
+	for _, doc := range c.documents {
+		if doc == nil {
+			continue
+		}
+		size += documentSize + estimateStringSize(doc.id) + estimateValueSize(doc.data)
+	}
+	return size
+}
+
+// estimateSize approximates the memory held by the index's entries.
+func (fi *fieldIndex) estimateSize() int64 {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+
+	entrySize := int64(unsafe.Sizeof(indexEntry{}))
+	size := int64(0)
+	fi.tree.Ascend(func(item btree.Item) bool {
+		entry := item.(indexEntry)
+
+		// Each item sits in a B-tree node as an interface value
+		size += interfaceSize + entrySize + sliceSize
+		for _, value := range entry.key.values {
+			size += interfaceSize + estimateValueSize(value)
+		}
+
+		size += mapHeaderSize
+		for docID := range entry.docIDs {
+			size += estimateStringSize(docID) + mapEntryPadding
+		}
+		return true
+	})
+	return size
+}
+
+// estimateStringSize approximates the memory held by a string.
+func estimateStringSize(s string) int64 {
+	return stringSize + int64(len(s))
+}
+
+// estimateValueSize approximates the memory held by a document value, not
+// counting the interface header that refers to it.
+func estimateValueSize(value any) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return estimateStringSize(v)
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, int64, uint, uint64, uintptr, float64:
+		return 8
+	case time.Time:
+		return int64(unsafe.Sizeof(v))
+	case map[string]any:
+		size := int64(mapHeaderSize)
+		for key, elem := range v {
+			size += estimateStringSize(key) + interfaceSize + mapEntryPadding + estimateValueSize(elem)
+		}
+		return size
+	case []any:
+		size := sliceSize
+		for _, elem := range v {
+			size += interfaceSize + estimateValueSize(elem)
+		}
+		return size
+	case []string:
+		size := sliceSize
+		for _, elem := range v {
+			size += estimateStringSize(elem)
+		}
+		return size
+	case []map[string]any:
+		size := sliceSize
+		for _, elem := range v {
+			size += pointerSize + estimateValueSize(elem)
+		}
+		return size
+	}
+
+	// Fall back to reflection for other slices and fixed-size values
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice {
+		return sliceSize + int64(rv.Len())*int64(rv.Type().Elem().Size())
+	}
+	return int64(rv.Type().Size())
+}
@@ -0,0 +1,61 @@
+package gostore
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestEstimateMemory tests that the estimate is consistent and grows with
+// documents and indexes.
+func TestEstimateMemory(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	empty := s.EstimateMemory()
+
+	id, _ := s.Insert(map[string]any{"name": "Alice", "tags": []any{"a", "b"}, "score": 10})
+	one := s.EstimateMemory()
+	if one.Documents <= empty.Documents || one.Handles <= empty.Handles {
+		t.Errorf("Expected estimate to grow after insert: %+v -> %+v", empty, one)
+	}
+	if one != s.EstimateMemory() {
+		t.Error("Expected repeated estimates of unchanged data to match")
+	}
+	if one.Total != one.Documents+one.Handles+one.Indexes {
+		t.Errorf("Expected Total to sum the components, got %+v", one)
+	}
+
+	_ = s.CreateIndex("by_name", []string{"name"})
+	indexed := s.EstimateMemory()
+	if indexed.Indexes <= one.Indexes {
+		t.Errorf("Expected index estimate to grow, got %d -> %d", one.Indexes, indexed.Indexes)
+	}
+
+	_ = s.Patch(id, map[string]any{"bio": strings.Repeat("x", 1000)})
+	larger := s.EstimateMemory()
+	if larger.Documents < indexed.Documents+1000 {
+		t.Errorf("Expected a 1000-byte field to be reflected, got %d -> %d", indexed.Documents, larger.Documents)
+	}
+
+	s.Close()
+	if stats := s.EstimateMemory(); stats != (MemoryStats{}) {
+		t.Errorf("Expected zero stats for a closed store, got %+v", stats)
+	}
+}
+
+// TestEstimateValueSize tests size estimates for individual values.
+func TestEstimateValueSize(t *testing.T) {
+	if size := estimateValueSize(nil); size != 0 {
+		t.Errorf("Expected 0 for nil, got %d", size)
+	}
+	if small, big := estimateValueSize("ab"), estimateValueSize("abcd"); big-small != 2 {
+		t.Errorf("Expected string size to track length, got %d and %d", small, big)
+	}
+	if size := estimateValueSize([]int64{1, 2, 3}); size != sliceSize+24 {
+		t.Errorf("Expected typed slice to be sized by reflection, got %d", size)
+	}
+	nested := map[string]any{"inner": map[string]any{"k": "v"}}
+	if estimateValueSize(nested) <= estimateValueSize(map[string]any{"inner": nil}) {
+		t.Error("Expected nested maps to be counted")
+	}
+}
@@ -0,0 +1,98 @@
+package gostore
+
+import (
+	"time"
+)
+
+// Merge copies every live document of other into s under its original ID and
+// indexes it in s. When an ID already exists in s, onConflict receives the
+// existing document and the incoming one and returns the document to keep;
+// returning nil, or passing a nil onConflict, keeps the existing document.
+// IDs that are soft-deleted in s are left alone. Merged documents receive
+// fresh versions from s. The merge is applied atomically with s locked, so
+// onConflict must not call back into s. Both stores must be open.
+func (s *Store) Merge(other *Store, onConflict func(a, b *DocumentResult) *DocumentResult) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+	if err := other.ensureOpen(); err != nil {
+		return err
+	}
+
+	if other == s {
+		return nil // Every document would conflict with itself
+	}
+
+	// Copy other's documents before locking s so the two stores are never
+	// locked together
+	incoming := other.liveDocuments()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, doc := range incoming {
+		if _, trashed := s.trash[doc.id]; trashed {
+			continue
+		}
+
+		entry, exists := s.handles[doc.id]
+		if !exists {
+			if err := s.validateDocument(doc.data); err != nil {
+				return err
+			}
+			s.insertLocked(doc.id, doc.data)
+			continue
+		}
+
+		if onConflict == nil {
+			continue
+		}
+
+		current, exists := s.collection.Get(entry.handle.index)
+		if !exists {
+			continue
+		}
+
+		chosen := onConflict(
+			&DocumentResult{
+				ID:        doc.id,
+				Data:      current.data,
+				Version:   current.version,
+				CreatedAt: current.createdAt,
+				UpdatedAt: current.updatedAt,
+			},
+			&DocumentResult{
+				ID:        doc.id,
+				Data:      doc.data,
+				Version:   doc.version,
+				CreatedAt: doc.createdAt,
+				UpdatedAt: doc.updatedAt,
+			},
+		)
+		if chosen == nil || chosen.Data == nil {
+			continue
+		}
+
+		if err := s.updateLocked(doc.id, chosen.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// liveDocuments returns copies of every document that has not expired.
+func (s *Store) liveDocuments() []*Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	documents := s.collection.GetAllValid()
+	live := documents[:0]
+	for _, doc := range documents {
+		if entry, exists := s.handles[doc.id]; exists && !entry.handle.expired(now) {
+			live = append(live, doc)
+		}
+	}
+	return live
+}
@@ -0,0 +1,60 @@
+package gostore
+
+import (
+	"testing"
+)
+
+// TestMerge tests fanning documents from one store into another.
+func TestMerge(t *testing.T) {
+	dst := NewStore()
+	defer dst.Close()
+	src := NewStore()
+	defer src.Close()
+
+	_ = dst.CreateIndex("by_city", []string{"city"})
+
+	shared, _ := dst.Insert(map[string]any{"city": "NYC", "score": 1})
+	_ = src.InsertWithID(shared, map[string]any{"city": "LA", "score": 5})
+	fresh, _ := src.Insert(map[string]any{"city": "SF"})
+
+	// Keep the higher score on conflict
+	err := dst.Merge(src, func(a, b *DocumentResult) *DocumentResult {
+		if b.Data["score"].(int) > a.Data["score"].(int) {
+			return b
+		}
+		return a
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	if doc, _ := dst.Get(shared); doc.Data["city"] != "LA" {
+		t.Errorf("Expected conflict to resolve to incoming document, got %v", doc.Data)
+	}
+	if doc, err := dst.Get(fresh); err != nil || doc.Data["city"] != "SF" {
+		t.Errorf("Expected new document to be merged under its ID, got %v (err=%v)", doc, err)
+	}
+	if results, _ := dst.Lookup("by_city", []any{"NYC"}); len(results) != 0 {
+		t.Errorf("Expected index to follow the conflict resolution, got %d", len(results))
+	}
+	if results, _ := dst.Lookup("by_city", []any{"SF"}); len(results) != 1 {
+		t.Errorf("Expected merged document to be indexed, got %d", len(results))
+	}
+
+	// A nil callback keeps existing documents
+	_ = src.Update(shared, map[string]any{"city": "Boston"})
+	if err := dst.Merge(src, nil); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if doc, _ := dst.Get(shared); doc.Data["city"] != "LA" {
+		t.Errorf("Expected existing document to be kept, got %v", doc.Data)
+	}
+	if src.Count() != 2 || dst.Count() != 2 {
+		t.Errorf("Expected 2 documents in each store, got %d and %d", src.Count(), dst.Count())
+	}
+
+	src.Close()
+	if err := dst.Merge(src, nil); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
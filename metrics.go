@@ -0,0 +1,73 @@
+package gostore
+
+import "time"
+
+// MetricsHook receives the latency of store operations, for example to feed
+// Prometheus histograms without this package depending on a metrics library.
+// Each method is called once per operation, whether or not it succeeded,
+// after the store's lock has been released, so a slow hook never holds up
+// other readers and writers. Hooks may be called concurrently.
+//
+// OnInsert covers Insert, InsertWithID, InsertWithTTL and InsertIfAbsent;
+// OnUpdate covers Update and Patch; OnDelete covers Delete and
+// DeleteIfVersion; OnGet covers Get and GetRef; and OnLookup covers the Lookup
+// and LookupRange families that return documents.
+type MetricsHook interface {
+	OnInsert(dur time.Duration)
+	OnUpdate(dur time.Duration)
+	OnDelete(dur time.Duration)
+	OnGet(dur time.Duration)
+	OnLookup(dur time.Duration, results int)
+}
+
+// NoopMetricsHook implements MetricsHook by doing nothing. Embed it to
+// implement only the methods you need.
+type NoopMetricsHook struct{}
+
+func (NoopMetricsHook) OnInsert(time.Duration)      {}
+func (NoopMetricsHook) OnUpdate(time.Duration)      {}
+func (NoopMetricsHook) OnDelete(time.Duration)      {}
+func (NoopMetricsHook) OnGet(time.Duration)         {}
+func (NoopMetricsHook) OnLookup(time.Duration, int) {}
+
+// metricsHook boxes a MetricsHook so it can be swapped atomically.
+type metricsHook struct {
+	MetricsHook
+}
+
+// SetMetricsHook installs h to receive operation latencies. Passing nil
+// removes the hook, which is the default.
+func (s *Store) SetMetricsHook(h MetricsHook) {
+	if s == nil {
+		return
+	}
+
+	if h == nil {
+		s.metrics.Store(nil)
+		return
+	}
+	s.metrics.Store(&metricsHook{h})
+}
+
+// observe reports the time since start to the installed hook through record.
+// Deferring it before taking s.mu makes it run after the lock is released.
+func (s *Store) observe(start time.Time, record func(MetricsHook, time.Duration)) {
+	if s == nil {
+		return
+	}
+	if hook := s.metrics.Load(); hook != nil {
+		record(hook.MetricsHook, time.Since(start))
+	}
+}
+
+// observeLookup reports a lookup's latency and result count to the installed
+// hook. results points at the lookup's named result so the count is read when
+// the deferred call runs.
+func (s *Store) observeLookup(start time.Time, results *[]*DocumentResult) {
+	if s == nil {
+		return
+	}
+	if hook := s.metrics.Load(); hook != nil {
+		hook.OnLookup(time.Since(start), len(*results))
+	}
+}
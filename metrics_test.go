@@ -0,0 +1,90 @@
+package gostore
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingHook counts calls per operation and checks the store is unlocked.
+type recordingHook struct {
+	NoopMetricsHook
+	store    *Store
+	mu       sync.Mutex
+	calls    map[string]int
+	results  []int
+	underMu  bool // Set if a hook ran while the store's lock was held
+	negative bool // Set if a hook received a negative duration
+}
+
+func (h *recordingHook) record(op string, dur time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.calls[op]++
+	if dur < 0 {
+		h.negative = true
+	}
+	if h.store.mu.TryLock() {
+		h.store.mu.Unlock()
+	} else {
+		h.underMu = true
+	}
+}
+
+func (h *recordingHook) OnInsert(dur time.Duration) { h.record("insert", dur) }
+func (h *recordingHook) OnUpdate(dur time.Duration) { h.record("update", dur) }
+func (h *recordingHook) OnDelete(dur time.Duration) { h.record("delete", dur) }
+func (h *recordingHook) OnGet(dur time.Duration)    { h.record("get", dur) }
+
+func (h *recordingHook) OnLookup(dur time.Duration, results int) {
+	h.record("lookup", dur)
+	h.mu.Lock()
+	h.results = append(h.results, results)
+	h.mu.Unlock()
+}
+
+// TestMetricsHook tests that operations report to the hook after unlocking.
+func TestMetricsHook(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	hook := &recordingHook{store: s, calls: make(map[string]int)}
+	s.SetMetricsHook(hook)
+	_ = s.CreateIndex("by_n", []string{"n"})
+
+	id, _ := s.Insert(map[string]any{"n": 1})
+	_ = s.InsertWithID("fixed", map[string]any{"n": 1})
+	_, _ = s.InsertWithTTL(map[string]any{"n": 2}, time.Hour)
+	_ = s.Update(id, map[string]any{"n": 1})
+	_ = s.Patch(id, map[string]any{"extra": true})
+	_, _ = s.Get(id)
+	_, _ = s.Get("missing") // Failed operations are reported too
+	_, _ = s.Lookup("by_n", []any{1})
+	_, _ = s.LookupRange("by_n", []any{1}, []any{3})
+	_, _ = s.LookupRangeOpts("by_n", []any{1}, []any{2}, RangeOptions{})
+	_ = s.Delete("fixed")
+
+	want := map[string]int{"insert": 3, "update": 2, "delete": 1, "get": 2, "lookup": 3}
+	for op, count := range want {
+		if hook.calls[op] != count {
+			t.Errorf("Expected %d %s calls, got %d", count, op, hook.calls[op])
+		}
+	}
+	if got := hook.results; len(got) != 3 || got[0] != 2 || got[1] != 3 || got[2] != 2 {
+		t.Errorf("Expected lookup result counts [2 3 2], got %v", got)
+	}
+	if hook.underMu {
+		t.Error("Expected hooks to run after the store's lock is released")
+	}
+	if hook.negative {
+		t.Error("Expected non-negative durations")
+	}
+
+	// Removing the hook stops reporting
+	s.SetMetricsHook(nil)
+	_, _ = s.Insert(map[string]any{"n": 3})
+	if hook.calls["insert"] != 3 {
+		t.Errorf("Expected no calls after removing the hook, got %d inserts", hook.calls["insert"])
+	}
+}
@@ -0,0 +1,108 @@
+package gostore
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// ExportNDJSON writes every live document to w as newline-delimited JSON, one
+// {"id": ..., "version": ..., "data": {...}} object per line, and returns the
+// number of documents written. The export is a point-in-time view taken when
+// it starts, but documents are not copied up front: each is encoded straight
+// from the store as it is reached, so the output is never buffered as a whole.
+func (s *Store) ExportNDJSON(w io.Writer) (int, error) {
+	if err := s.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	encoder := json.NewEncoder(w)
+
+	written := 0
+	for _, doc := range s.snapshotDocuments() {
+		result := DocumentResult{
+			ID:        doc.id,
+			Data:      doc.data,
+			Version:   doc.version,
+			CreatedAt: doc.createdAt,
+			UpdatedAt: doc.updatedAt,
+		}
+		if err := encoder.Encode(result); err != nil {
+			return written, err
+		}
+		written++
+	}
+
+	return written, nil
+}
+
+// snapshotDocuments captures every live document under a single read lock,
+// in internal index order, without copying its data. Writes replace a
+// document's data rather than modifying it, so the captured documents stay a
+// consistent view while they are read, but they must not be modified.
+func (s *Store) snapshotDocuments() []*Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.snapshotDocumentsLocked()
+}
+
+// snapshotDocumentsLocked is snapshotDocuments for a caller that already holds
+// s.mu.
+func (s *Store) snapshotDocumentsLocked() []*Document {
+	now := time.Now()
+	handles := make([]*DocumentHandle, 0, len(s.handles))
+	for _, entry := range s.handles {
+		if !entry.handle.expired(now) {
+			handles = append(handles, entry.handle)
+		}
+	}
+	slices.SortFunc(handles, func(a, b *DocumentHandle) int {
+		return cmp.Compare(a.index, b.index)
+	})
+
+	documents := make([]*Document, 0, len(handles))
+	for _, handle := range handles {
+		if doc, exists := s.collection.get(handle.index, false); exists && doc.id == handle.id {
+			documents = append(documents, doc)
+		}
+	}
+	return documents
+}
+
+// ImportNDJSON reads documents in the format written by ExportNDJSON and
+// inserts them under their original IDs, returning how many were imported.
+// Imported documents receive fresh versions and timestamps from this store.
+// Import stops at the first malformed line or at an ID that already exists in
+// the store; documents imported before that point are kept.
+func (s *Store) ImportNDJSON(r io.Reader) (int, error) {
+	if err := s.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	decoder := json.NewDecoder(r)
+
+	imported := 0
+	for line := 1; ; line++ {
+		var result DocumentResult
+		if err := decoder.Decode(&result); err != nil {
+			if errors.Is(err, io.EOF) {
+				return imported, nil
+			}
+			return imported, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if result.ID == "" || result.Data == nil {
+			return imported, fmt.Errorf("line %d: %w", line, ErrInvalidDocument)
+		}
+
+		if err := s.InsertWithID(result.ID, result.Data); err != nil {
+			return imported, fmt.Errorf("line %d: %w", line, err)
+		}
+		imported++
+	}
+}
@@ -0,0 +1,132 @@
+package gostore
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestNDJSONRoundTrip tests exporting a store and importing it elsewhere.
+func TestNDJSONRoundTrip(t *testing.T) {
+	src := NewStore()
+	defer src.Close()
+
+	ids := make(map[string]map[string]any)
+	for i := range 5 {
+		doc := map[string]any{"n": float64(i), "tags": []any{"a", "b"}}
+		id, _ := src.Insert(doc)
+		ids[id] = doc
+	}
+
+	var buf bytes.Buffer
+	written, err := src.ExportNDJSON(&buf)
+	if err != nil {
+		t.Fatalf("ExportNDJSON failed: %v", err)
+	}
+	if written != 5 {
+		t.Errorf("Expected 5 documents written, got %d", written)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 5 {
+		t.Errorf("Expected 5 lines, got %d", lines)
+	}
+
+	dst := NewStore()
+	defer dst.Close()
+	_ = dst.CreateIndex("by_n", []string{"n"})
+
+	imported, err := dst.ImportNDJSON(&buf)
+	if err != nil {
+		t.Fatalf("ImportNDJSON failed: %v", err)
+	}
+	if imported != 5 {
+		t.Errorf("Expected 5 documents imported, got %d", imported)
+	}
+
+	for id, expected := range ids {
+		doc, err := dst.Get(id)
+		if err != nil {
+			t.Fatalf("Expected imported document %s, got %v", id, err)
+		}
+		if !reflect.DeepEqual(doc.Data, expected) {
+			t.Errorf("Expected %v, got %v", expected, doc.Data)
+		}
+	}
+
+	if results, _ := dst.Lookup("by_n", []any{2}); len(results) != 1 {
+		t.Errorf("Expected imported documents to be indexed, got %d", len(results))
+	}
+}
+
+// TestImportNDJSONErrors tests malformed input and duplicate IDs.
+func TestImportNDJSONErrors(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	input := `{"id":"a","version":1,"data":{"x":1}}
+{"id":"a","version":2,"data":{"x":2}}
+`
+	imported, err := s.ImportNDJSON(strings.NewReader(input))
+	if imported != 1 || !errors.Is(err, ErrDocumentExists) {
+		t.Errorf("Expected 1 import and ErrDocumentExists, got %d, %v", imported, err)
+	}
+
+	imported, err = s.ImportNDJSON(strings.NewReader(`{"id":"b","data":null}`))
+	if imported != 0 || !errors.Is(err, ErrInvalidDocument) {
+		t.Errorf("Expected ErrInvalidDocument, got %d, %v", imported, err)
+	}
+
+	if _, err := s.ImportNDJSON(strings.NewReader(`{"id":`)); err == nil {
+		t.Error("Expected error for truncated input")
+	}
+}
+
+// writeHook is a writer that runs a function before its first write.
+type writeHook struct {
+	bytes.Buffer
+	before func()
+}
+
+func (w *writeHook) Write(p []byte) (int, error) {
+	if w.before != nil {
+		w.before()
+		w.before = nil
+	}
+	return w.Buffer.Write(p)
+}
+
+// TestExportNDJSONPointInTime tests that writes made while an export is in
+// progress don't show up in it.
+func TestExportNDJSONPointInTime(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	first, _ := s.Insert(map[string]any{"n": 1})
+	second, _ := s.Insert(map[string]any{"n": 2})
+
+	w := &writeHook{before: func() {
+		_ = s.Update(second, map[string]any{"n": 20})
+		_ = s.Delete(first)
+		_, _ = s.Insert(map[string]any{"n": 3})
+	}}
+	written, err := s.ExportNDJSON(w)
+	if err != nil {
+		t.Fatalf("ExportNDJSON failed: %v", err)
+	}
+	if written != 2 {
+		t.Errorf("Expected the 2 documents present when the export began, got %d", written)
+	}
+
+	exported := NewStore()
+	defer exported.Close()
+	if _, err := exported.ImportNDJSON(&w.Buffer); err != nil {
+		t.Fatalf("ImportNDJSON failed: %v", err)
+	}
+	if doc, err := exported.Get(first); err != nil || doc.Data["n"] != float64(1) {
+		t.Errorf("Expected the deleted document as it was, got %v, %v", doc, err)
+	}
+	if doc, err := exported.Get(second); err != nil || doc.Data["n"] != float64(2) {
+		t.Errorf("Expected the updated document's earlier data, got %v, %v", doc, err)
+	}
+}
@@ -0,0 +1,90 @@
+package gostore
+
+import (
+	"testing"
+)
+
+// TestSnapshotIsolation tests that writes made after a snapshot are not
+// visible through it.
+func TestSnapshotIsolation(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_status", []string{"status"})
+
+	kept, _ := s.Insert(map[string]any{"status": "open"})
+	changed, _ := s.Insert(map[string]any{"status": "open"})
+	deleted, _ := s.Insert(map[string]any{"status": "open"})
+
+	snap := s.Snapshot()
+
+	_ = s.Update(changed, map[string]any{"status": "closed"})
+	_ = s.Delete(deleted)
+	added, _ := s.Insert(map[string]any{"status": "open"})
+
+	if snap.Count() != 3 {
+		t.Errorf("Expected 3 documents in snapshot, got %d", snap.Count())
+	}
+
+	doc, err := snap.Get(changed)
+	if err != nil || doc.Data["status"] != "open" || doc.Version != 2 {
+		t.Errorf("Expected pre-update document, got %+v (err=%v)", doc, err)
+	}
+	if _, err := snap.Get(deleted); err != nil {
+		t.Errorf("Expected deleted document to remain in snapshot, got %v", err)
+	}
+	if _, err := snap.Get(added); err != ErrDocumentNotFound {
+		t.Errorf("Expected later insert to be invisible, got %v", err)
+	}
+
+	results, err := snap.Lookup("by_status", []any{"open"})
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 open documents in snapshot, got %d", len(results))
+	}
+	if results, _ := snap.Lookup("by_status", []any{"closed"}); len(results) != 0 {
+		t.Errorf("Expected no closed documents in snapshot, got %d", len(results))
+	}
+	if _, err := snap.Lookup("missing", []any{"open"}); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+
+	stream := snap.Stream(10)
+	defer stream.Close()
+	seen := make(map[string]bool)
+	for {
+		result, err := stream.Next()
+		if err != nil {
+			break
+		}
+		seen[result.ID] = true
+	}
+	if len(seen) != 3 || !seen[kept] || !seen[changed] || !seen[deleted] {
+		t.Errorf("Expected stream to yield the snapshot's documents, got %v", seen)
+	}
+
+	// Mutating results must not leak into the snapshot
+	doc.Data["status"] = "mutated"
+	if doc, _ := snap.Get(changed); doc.Data["status"] != "open" {
+		t.Errorf("Snapshot was modified through a result: %v", doc.Data)
+	}
+}
+
+// TestSnapshotClosedStore tests snapshotting a closed store.
+func TestSnapshotClosedStore(t *testing.T) {
+	s := NewStore()
+	s.Close()
+
+	snap := s.Snapshot()
+	if _, err := snap.Get("any"); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+	if _, err := snap.Lookup("any", nil); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+	if _, err := snap.Stream(1).Next(); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed from stream, got %v", err)
+	}
+}
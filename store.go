@@ -1,12 +1,19 @@
 package gostore
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"math"
 	"reflect"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/google/btree"
 	"github.com/google/uuid"
@@ -14,28 +21,48 @@ import (
 
 // Custom error types for better error handling
 var (
-	ErrDocumentNotFound = errors.New("document not found")
-	ErrDocumentDeleted  = errors.New("document has been deleted")
-	ErrIndexExists      = errors.New("index already exists")
-	ErrEmptyIndex       = errors.New("cannot create empty index")
-	ErrIndexNotFound    = errors.New("index does not exist")
-	ErrStreamClosed     = errors.New("stream closed")
-	ErrStoreClosed      = errors.New("store closed")
-	ErrInvalidDocument  = errors.New("invalid document")
+	ErrDocumentNotFound   = errors.New("document not found")
+	ErrDocumentDeleted    = errors.New("document has been deleted")
+	ErrIndexExists        = errors.New("index already exists")
+	ErrEmptyIndex         = errors.New("cannot create empty index")
+	ErrIndexNotFound      = errors.New("index does not exist")
+	ErrStreamClosed       = errors.New("stream closed")
+	ErrStoreClosed        = errors.New("store closed")
+	ErrStoreNil           = errors.New("method called on a nil store")
+	ErrInvalidDocument    = errors.New("invalid document")
+	ErrNoTTL              = errors.New("document has no ttl")
+	ErrInvalidTTL         = errors.New("ttl must be positive")
+	ErrInvalidAggregate   = errors.New("invalid aggregate operation")
+	ErrDocumentExists     = errors.New("document already exists")
+	ErrIDExists           = fmt.Errorf("id is already taken: %w", ErrDocumentExists) // A caller-supplied or generated ID is taken
+	ErrInvalidDegree      = errors.New("btree degree must be at least 2")
+	ErrInvalidKinds       = errors.New("index must declare one kind per field")
+	ErrTypeMismatch       = errors.New("indexed value has the wrong type")
+	ErrTxnDone            = errors.New("transaction already committed or rolled back")
+	ErrVersionConflict    = errors.New("document version does not match")
+	ErrChangesUnavailable = errors.New("changes since the requested version are no longer retained")
+	ErrDocumentTooLarge   = errors.New("document exceeds the maximum size")
+	ErrMultipleMatches    = errors.New("more than one document matches")
+	ErrBatchTooLarge      = errors.New("batch exceeds the document cap")
+	ErrNilComparator      = errors.New("index comparator is nil")
 )
 
 // Document represents a stable document in the collection
 type Document struct {
-	id      string
-	data    map[string]any
-	version uint64
-	deleted bool
+	id        string
+	data      map[string]any
+	version   uint64
+	deleted   bool
+	trashed   bool // Soft-deleted: data retained but hidden until restored or purged
+	createdAt time.Time
+	updatedAt time.Time
 }
 
 // Collection manages stable document storage with auto-scaling
 type Collection struct {
 	documents []*Document
-	freeSlots []int // Indices of deleted documents available for reuse
+	freeSlots []int        // Indices of deleted documents available for reuse
+	count     atomic.Int64 // Number of live documents
 	mu        sync.RWMutex
 }
 
@@ -49,15 +76,25 @@ func NewCollection() *Collection {
 
 // Insert adds a new document to the collection and returns its stable index
 func (c *Collection) Insert(id string, data map[string]any, version uint64) int {
+	now := time.Now()
+	return c.insertAt(id, data, version, now, now)
+}
+
+// insertAt adds a document with the given timestamps, so that copies of an
+// existing document keep its original creation and modification times.
+func (c *Collection) insertAt(id string, data map[string]any, version uint64, createdAt, updatedAt time.Time) int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	doc := &Document{
-		id:      id,
-		data:    copyDocument(data),
-		version: version,
-		deleted: false,
+		id:        id,
+		data:      copyDocument(data),
+		version:   version,
+		deleted:   false,
+		createdAt: createdAt,
+		updatedAt: updatedAt,
 	}
+	c.count.Add(1)
 
 	// Reuse a free slot if available
 	if len(c.freeSlots) > 0 {
@@ -72,6 +109,18 @@ func (c *Collection) Insert(id string, data map[string]any, version uint64) int
 	return len(c.documents) - 1
 }
 
+// adopt appends doc to the collection without copying its data, returning its
+// slot. Document data is replaced rather than modified in place, so data taken
+// from another collection can be shared this way.
+func (c *Collection) adopt(doc *Document) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count.Add(1)
+	c.documents = append(c.documents, doc)
+	return len(c.documents) - 1
+}
+
 // Update modifies an existing document in place
 func (c *Collection) Update(index int, data map[string]any, version uint64) bool {
 	c.mu.Lock()
@@ -82,18 +131,26 @@ func (c *Collection) Update(index int, data map[string]any, version uint64) bool
 	}
 
 	doc := c.documents[index]
-	if doc == nil || doc.deleted {
+	if doc == nil || doc.deleted || doc.trashed {
 		return false
 	}
 
 	// Update in place - this is the key optimization
 	doc.data = copyDocument(data)
 	doc.version = version
+	doc.updatedAt = time.Now()
 	return true
 }
 
 // Get retrieves a document by index
 func (c *Collection) Get(index int) (*Document, bool) {
+	return c.get(index, true)
+}
+
+// get retrieves a document by index, deep copying its data when copyData is
+// set. Update replaces a document's data rather than modifying it, so an
+// uncopied map stays a consistent snapshot as long as nobody writes to it.
+func (c *Collection) get(index int, copyData bool) (*Document, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -102,16 +159,23 @@ func (c *Collection) Get(index int) (*Document, bool) {
 	}
 
 	doc := c.documents[index]
-	if doc == nil || doc.deleted {
+	if doc == nil || doc.deleted || doc.trashed {
 		return nil, false
 	}
 
-	// Return a copy to maintain immutability for callers
+	data := doc.data
+	if copyData {
+		// Return a copy to maintain immutability for callers
+		data = copyDocument(data)
+	}
+
 	return &Document{
-		id:      doc.id,
-		data:    copyDocument(doc.data),
-		version: doc.version,
-		deleted: doc.deleted,
+		id:        doc.id,
+		data:      data,
+		version:   doc.version,
+		deleted:   doc.deleted,
+		createdAt: doc.createdAt,
+		updatedAt: doc.updatedAt,
 	}, true
 }
 
@@ -129,6 +193,11 @@ func (c *Collection) Delete(index int) bool {
 		return false
 	}
 
+	// Trashed documents were already discounted when they were soft-deleted
+	if !doc.trashed {
+		c.count.Add(-1)
+	}
+
 	// Mark as deleted and clear data immediately
 	doc.deleted = true
 	doc.data = nil
@@ -137,6 +206,22 @@ func (c *Collection) Delete(index int) bool {
 	return true
 }
 
+// reset removes every document, including soft-deleted ones, and releases the
+// backing storage. Slots are numbered from zero again afterwards.
+func (c *Collection) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.documents = make([]*Document, 0)
+	c.freeSlots = make([]int, 0)
+	c.count.Store(0)
+}
+
+// Count returns the number of live documents without scanning the collection.
+func (c *Collection) Count() int {
+	return int(c.count.Load())
+}
+
 // GetAllValid returns all non-deleted documents
 func (c *Collection) GetAllValid() []*Document {
 	c.mu.RLock()
@@ -144,27 +229,77 @@ func (c *Collection) GetAllValid() []*Document {
 
 	var result []*Document
 	for _, doc := range c.documents {
-		if doc != nil && !doc.deleted {
+		if doc != nil && !doc.deleted && !doc.trashed {
 			result = append(result, &Document{
-				id:      doc.id,
-				data:    copyDocument(doc.data),
-				version: doc.version,
-				deleted: doc.deleted,
+				id:        doc.id,
+				data:      copyDocument(doc.data),
+				version:   doc.version,
+				deleted:   doc.deleted,
+				createdAt: doc.createdAt,
+				updatedAt: doc.updatedAt,
 			})
 		}
 	}
 	return result
 }
 
+// TrimFreeSlots reallocates the documents and freeSlots slices to fit their
+// contents, releasing excess capacity to the GC. Empty slots at the tail of
+// the collection are dropped entirely since no handle can refer to them.
+func (c *Collection) TrimFreeSlots() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := len(c.documents)
+	for end > 0 && c.documents[end-1] == nil {
+		end--
+	}
+
+	documents := make([]*Document, end)
+	copy(documents, c.documents)
+
+	freeSlots := make([]int, 0, len(c.freeSlots))
+	for _, index := range c.freeSlots {
+		if index < end {
+			freeSlots = append(freeSlots, index)
+		}
+	}
+
+	c.documents = documents
+	c.freeSlots = slices.Clip(freeSlots)
+}
+
+// compact moves every document down over the free slots so the slice has no
+// holes, returning each document's new index keyed by its old one.
+func (c *Collection) compact() map[int]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	documents := make([]*Document, 0, len(c.documents)-len(c.freeSlots))
+	moved := make(map[int]int, cap(documents))
+	for index, doc := range c.documents {
+		if doc == nil {
+			continue
+		}
+		moved[index] = len(documents)
+		documents = append(documents, doc)
+	}
+
+	c.documents = documents
+	c.freeSlots = make([]int, 0)
+	return moved
+}
+
 // DocumentHandle provides a versioned reference to a stable document location.
 // It tracks the current version and provides atomic access to document state
 // without requiring complex reference counting.
 type DocumentHandle struct {
-	id       string
-	version  uint64
-	index    int // Stable index in the collection
-	document *Document
-	mu       sync.RWMutex
+	id        string
+	version   uint64
+	index     int // Stable index in the collection
+	document  *Document
+	expiresAt time.Time // Zero when the document has no TTL
+	mu        sync.RWMutex
 }
 
 // HandleEntry consolidates handle management with index membership tracking
@@ -176,11 +311,15 @@ type HandleEntry struct {
 // indexKey represents a composite key for index entries.
 type indexKey struct {
 	values []any
+	cmp    func(a, b []any) int // Optional ordering from CreateIndexWithComparator
 }
 
 // Less implements btree.Item interface for ordering index keys.
 func (ik indexKey) Less(other btree.Item) bool {
 	otherKey := other.(indexKey)
+	if ik.cmp != nil {
+		return ik.cmp(ik.values, otherKey.values) < 0
+	}
 
 	// Compare values element by element
 	minLen := min(len(otherKey.values), len(ik.values))
@@ -206,25 +345,74 @@ func (ie indexEntry) Less(other btree.Item) bool {
 	return ie.key.Less(other.(indexEntry).key)
 }
 
+// appendDocIDs appends the entry's document IDs to dst in ascending ID order.
+// Documents sharing a key therefore come back in a stable order; since IDs are
+// UUIDv7 by default, that order also follows insertion time.
+func (ie indexEntry) appendDocIDs(dst []string) []string {
+	start := len(dst)
+	for docID := range ie.docIDs {
+		dst = append(dst, docID)
+	}
+	slices.Sort(dst[start:])
+	return dst
+}
+
 // fieldIndex is a B-tree based index on one or more document fields.
 type fieldIndex struct {
 	name       string
 	fields     []string
+	filter     func(map[string]any) bool         // Optional membership predicate for partial indexes
+	transform  func(any) any                     // Optional normalization applied to key values
+	keyFunc    func(map[string]any) (any, bool)  // Derives the key for functional indexes instead of fields
+	kinds      []reflect.Kind                    // Optional per-field value kinds for typed indexes
+	strict     bool                              // Reject writes whose values don't match kinds
+	explode    func(any) []any                   // Splits the field's value into one key per element for multi-key indexes
+	array      bool                              // explode indexes the elements of an array field
+	tokenize   func(string) []string             // Splits text into search terms for text indexes
+	cmp        func(a, b []any) int              // Optional key ordering; nil orders keys with compareKeys
+	hook       *atomic.Pointer[func(IndexEvent)] // The owning store's index hook; nil for snapshot indexes
+	degree     int                               // B-tree degree
 	tree       *btree.BTree
 	collection *Collection // Reference to the stable collection
 	mu         sync.RWMutex
 }
 
+// defaultIndexDegree is the B-tree degree used by CreateIndex.
+const defaultIndexDegree = 32
+
 // newFieldIndex creates a new field index with the specified name and fields.
 func newFieldIndex(name string, fields []string, collection *Collection) *fieldIndex {
+	return newFieldIndexWithDegree(name, fields, collection, defaultIndexDegree)
+}
+
+// newFieldIndexWithDegree creates a new field index backed by a B-tree of the
+// given degree.
+func newFieldIndexWithDegree(name string, fields []string, collection *Collection, degree int) *fieldIndex {
 	return &fieldIndex{
 		name:       name,
 		fields:     fields,
-		tree:       btree.New(32),
+		degree:     degree,
+		tree:       btree.New(degree),
 		collection: collection,
 	}
 }
 
+// emptyCopy creates an index with the same configuration but no entries,
+// bound to the given collection.
+func (fi *fieldIndex) emptyCopy(collection *Collection) *fieldIndex {
+	index := newFieldIndexWithDegree(fi.name, fi.fields, collection, fi.degree)
+	index.filter = fi.filter
+	index.transform = fi.transform
+	index.keyFunc = fi.keyFunc
+	index.kinds = fi.kinds
+	index.strict = fi.strict
+	index.explode = fi.explode
+	index.array = fi.array
+	index.tokenize = fi.tokenize
+	index.cmp = fi.cmp
+	return index
+}
+
 // insertDocument adds a document to the index if it has values for all indexed fields.
 func (fi *fieldIndex) insertDocument(handle *DocumentHandle) bool {
 	doc, exists := fi.collection.Get(handle.index)
@@ -232,72 +420,123 @@ func (fi *fieldIndex) insertDocument(handle *DocumentHandle) bool {
 		return false
 	}
 
-	keyValues := fi.extractKeyValues(doc.data)
-	if keyValues == nil {
+	keys := fi.extractKeys(doc.data)
+	if len(keys) == 0 {
 		return false // Document doesn't have all required fields
 	}
 
 	fi.mu.Lock()
 	defer fi.mu.Unlock()
-	fi.addToIndex(handle.id, keyValues)
+	for _, keyValues := range keys {
+		fi.addToIndex(handle.id, keyValues)
+	}
 	return true
 }
 
-// updateDocument updates a document's position in the index.
-func (fi *fieldIndex) updateDocument(handle *DocumentHandle, oldData map[string]any) bool {
+// insertBatch adds many documents to the index under a single lock and reports
+// which of them were indexed.
+func (fi *fieldIndex) insertBatch(docIDs []string, data []map[string]any) []bool {
+	indexed := make([]bool, len(docIDs))
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	for i, docID := range docIDs {
+		keys := fi.extractKeys(data[i])
+		for _, keyValues := range keys {
+			fi.addToIndex(docID, keyValues)
+		}
+		indexed[i] = len(keys) > 0
+	}
+	return indexed
+}
+
+// updateDocument updates a document's position in the index. Keys considered
+// equal by keyEqual are left in place without touching the B-tree.
+func (fi *fieldIndex) updateDocument(handle *DocumentHandle, oldData map[string]any, keyEqual KeyEqualFunc) bool {
 	doc, exists := fi.collection.Get(handle.index)
 	if !exists {
 		return false
 	}
 
-	oldKeyValues := fi.extractKeyValues(oldData)
-	newKeyValues := fi.extractKeyValues(doc.data)
+	oldKeys := fi.extractKeys(oldData)
+	newKeys := fi.extractKeys(doc.data)
+
+	// Keys the comparator finds equal share an entry, whatever keyEqual says
+	if fi.cmp != nil {
+		keyEqual = func(a, b []any) bool { return fi.cmp(a, b) == 0 }
+	}
 
 	// Optimization: if indexed fields haven't changed, no work needed
-	if reflect.DeepEqual(oldKeyValues, newKeyValues) {
-		return oldKeyValues != nil // Return true if document was/is indexed
+	if slices.EqualFunc(oldKeys, newKeys, func(a, b []any) bool { return keyEqual(a, b) }) {
+		return len(oldKeys) > 0 // Return true if document was/is indexed
 	}
 
 	fi.mu.Lock()
 	defer fi.mu.Unlock()
 
-	// Remove old entry if it existed
-	wasIndexed := false
-	if oldKeyValues != nil {
-		wasIndexed = true
-		fi.removeFromIndex(handle.id, oldKeyValues)
+	// Remove old entries, then add the new ones
+	for _, keyValues := range oldKeys {
+		fi.removeFromIndex(handle.id, keyValues)
 	}
-
-	// Add new entry if document has all required fields
-	nowIndexed := false
-	if newKeyValues != nil {
-		nowIndexed = true
-		fi.addToIndex(handle.id, newKeyValues)
+	for _, keyValues := range newKeys {
+		fi.addToIndex(handle.id, keyValues)
 	}
 
-	return wasIndexed || nowIndexed
+	return len(oldKeys) > 0 || len(newKeys) > 0
 }
 
 // deleteDocument removes a document from the index.
 func (fi *fieldIndex) deleteDocument(docID string, data map[string]any) bool {
-	keyValues := fi.extractKeyValues(data)
-	if keyValues == nil {
+	keys := fi.extractKeys(data)
+	if len(keys) == 0 {
 		return false // Document wasn't indexed
 	}
 
 	fi.mu.Lock()
 	defer fi.mu.Unlock()
-	fi.removeFromIndex(docID, keyValues)
+	for _, keyValues := range keys {
+		fi.removeFromIndex(docID, keyValues)
+	}
 	return true
 }
 
+// purgeDocument removes every reference to a document ID from the index,
+// regardless of which key it is currently filed under.
+func (fi *fieldIndex) purgeDocument(docID string) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	var emptied []indexEntry
+	fi.tree.Ascend(func(item btree.Item) bool {
+		entry := item.(indexEntry)
+		if _, exists := entry.docIDs[docID]; exists {
+			delete(entry.docIDs, docID)
+			fi.notify(docID, entry.key.values, IndexRemoved)
+			if len(entry.docIDs) == 0 {
+				emptied = append(emptied, entry)
+			}
+		}
+		return true
+	})
+
+	// Clean up empty entries once iteration has finished
+	for _, entry := range emptied {
+		fi.tree.Delete(entry)
+	}
+}
+
 // removeFromIndex removes a document ID from an index entry.
 func (fi *fieldIndex) removeFromIndex(docID string, keyValues []any) {
-	searchEntry := indexEntry{key: indexKey{values: keyValues}}
+	searchEntry := indexEntry{key: fi.key(keyValues)}
 
 	if item := fi.tree.Get(searchEntry); item != nil {
 		entry := item.(indexEntry)
+		if _, exists := entry.docIDs[docID]; !exists {
+			return
+		}
 		delete(entry.docIDs, docID)
+		fi.notify(docID, entry.key.values, IndexRemoved)
 
 		// Clean up empty entries
 		if len(entry.docIDs) == 0 {
@@ -308,7 +547,7 @@ func (fi *fieldIndex) removeFromIndex(docID string, keyValues []any) {
 
 // addToIndex adds a document ID to an index entry.
 func (fi *fieldIndex) addToIndex(docID string, keyValues []any) {
-	searchEntry := indexEntry{key: indexKey{values: keyValues}}
+	searchEntry := indexEntry{key: fi.key(keyValues)}
 
 	if item := fi.tree.Get(searchEntry); item != nil {
 		// Add to existing entry
@@ -317,15 +556,116 @@ func (fi *fieldIndex) addToIndex(docID string, keyValues []any) {
 	} else {
 		// Create new entry
 		entry := indexEntry{
-			key:    indexKey{values: keyValues},
+			key:    fi.key(keyValues),
 			docIDs: map[string]struct{}{docID:{}},
 		}
 		fi.tree.ReplaceOrInsert(entry)
 	}
+	fi.notify(docID, keyValues, IndexAdded)
+}
+
+// key wraps values as a key ordered by the index's comparator.
+func (fi *fieldIndex) key(values []any) indexKey {
+	return indexKey{values: values, cmp: fi.cmp}
+}
+
+// compareKeys orders two keys the way the index's B-tree does.
+func (fi *fieldIndex) compareKeys(a, b []any) int {
+	if fi.cmp != nil {
+		return fi.cmp(a, b)
+	}
+	return compareKeys(a, b)
+}
+
+// extractKeys returns every key a document is filed under: the single key of
+// an ordinary index, one key per distinct element for a multi-key index, or
+// none if the document isn't indexed. Multi-key keys are in index order.
+func (fi *fieldIndex) extractKeys(data map[string]any) [][]any {
+	if fi.explode == nil {
+		if keyValues := fi.extractKeyValues(data); keyValues != nil {
+			return [][]any{keyValues}
+		}
+		return nil
+	}
+
+	if fi.filter != nil && !fi.filter(data) {
+		return nil
+	}
+
+	value, exists := data[fi.fields[0]]
+	if !exists || value == nil {
+		return nil
+	}
+
+	var keys [][]any
+	for _, element := range fi.explode(value) {
+		if element == nil {
+			continue
+		}
+		if keyValues := orderableKey(fi.transformValues([]any{element})); keyValues != nil {
+			keys = append(keys, keyValues)
+		}
+	}
+
+	slices.SortFunc(keys, fi.compareKeys)
+	return slices.CompactFunc(keys, func(a, b []any) bool { return fi.compareKeys(a, b) == 0 })
+}
+
+// arrayElements returns the elements of a slice or array value, or the value
+// itself if it is a scalar.
+func arrayElements(value any) []any {
+	if elements, ok := value.([]any); ok {
+		return elements
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []any{value}
+	}
+
+	elements := make([]any, v.Len())
+	for i := range elements {
+		elements[i] = v.Index(i).Interface()
+	}
+	return elements
+}
+
+// uniqueIDs drops repeated document IDs, keeping the first of each, for
+// multi-key indexes where a range can match a document under several keys.
+func (fi *fieldIndex) uniqueIDs(docIDs []string) []string {
+	if fi.explode == nil {
+		return docIDs
+	}
+
+	seen := make(map[string]struct{}, len(docIDs))
+	return slices.DeleteFunc(docIDs, func(docID string) bool {
+		if _, exists := seen[docID]; exists {
+			return true
+		}
+		seen[docID] = struct{}{}
+		return false
+	})
 }
 
 // extractKeyValues extracts the values for indexed fields from a document.
+// It returns nil when the document does not belong in the index.
 func (fi *fieldIndex) extractKeyValues(data map[string]any) []any {
+	if fi.filter != nil && !fi.filter(data) {
+		return nil
+	}
+
+	if fi.keyFunc != nil {
+		value, ok := fi.keyFunc(data)
+		if !ok || value == nil {
+			return nil
+		}
+		return orderableKey(fi.transformValues([]any{value}))
+	}
+
+	if fi.typeError(data) != nil {
+		return nil // Skip documents whose values don't match a typed index
+	}
+
 	values := make([]any, 0, len(fi.fields))
 
 	for _, field := range fi.fields {
@@ -336,22 +676,63 @@ func (fi *fieldIndex) extractKeyValues(data map[string]any) []any {
 		values = append(values, value)
 	}
 
+	return orderableKey(fi.transformValues(values))
+}
+
+// orderableKey returns values unchanged, or nil if any of them is a slice or
+// map. Such values have no meaningful order, so documents holding them in an
+// indexed field are left out of the index rather than being placed by their
+// formatted string representation.
+func orderableKey(values []any) []any {
+	for _, value := range values {
+		if value == nil {
+			continue // A transform may map values to nil
+		}
+		if kind := reflect.TypeOf(value).Kind(); kind == reflect.Slice || kind == reflect.Map {
+			return nil
+		}
+	}
 	return values
 }
 
+// typeError reports the first indexed value in data whose kind differs from
+// the one declared for its field. Missing and nil values are not checked, as
+// such documents are never indexed anyway.
+func (fi *fieldIndex) typeError(data map[string]any) error {
+	for i, kind := range fi.kinds {
+		value := data[fi.fields[i]]
+		if value == nil {
+			continue
+		}
+		if actual := reflect.TypeOf(value).Kind(); actual != kind {
+			return fmt.Errorf("%w: index %s expects %s for field %s, got %s", ErrTypeMismatch, fi.name, kind, fi.fields[i], actual)
+		}
+	}
+	return nil
+}
+
+// transformValues applies the index's transform to key or query values,
+// returning a new slice. Without a transform the values are returned as-is.
+func (fi *fieldIndex) transformValues(values []any) []any {
+	if fi.transform == nil || values == nil {
+		return values
+	}
+
+	transformed := make([]any, len(values))
+	for i, value := range values {
+		transformed[i] = fi.transform(value)
+	}
+	return transformed
+}
+
 // lookup finds document IDs that exactly match the given values.
 func (fi *fieldIndex) lookup(values []any) []string {
 	fi.mu.RLock()
 	defer fi.mu.RUnlock()
 
-	searchEntry := indexEntry{key: indexKey{values: values}}
+	searchEntry := indexEntry{key: fi.key(fi.transformValues(values))}
 	if item := fi.tree.Get(searchEntry); item != nil {
-		entry := item.(indexEntry)
-		result := make([]string, 0, len(entry.docIDs))
-		for docID := range entry.docIDs {
-			result = append(result, docID)
-		}
-		return result
+		return item.(indexEntry).appendDocIDs(nil)
 	}
 
 	return nil
@@ -363,512 +744,2807 @@ func (fi *fieldIndex) lookupRange(minValues, maxValues []any) []string {
 	defer fi.mu.RUnlock()
 
 	var result []string
-	minEntry := indexEntry{key: indexKey{values: minValues}}
-	maxEntry := indexEntry{key: indexKey{values: maxValues}}
+	minEntry := indexEntry{key: fi.key(fi.transformValues(minValues))}
+	maxEntry := indexEntry{key: fi.key(fi.transformValues(maxValues))}
 
 	fi.tree.AscendRange(minEntry, maxEntry, func(item btree.Item) bool {
-		entry := item.(indexEntry)
-		for docID := range entry.docIDs {
-			result = append(result, docID)
-		}
+		result = item.(indexEntry).appendDocIDs(result)
 		return true // Continue iteration
 	})
 
-	return result
+	return fi.uniqueIDs(result)
 }
 
-// DocumentResult holds the data and metadata for a document returned from a query.
-type DocumentResult struct {
-	ID      string
-	Data    map[string]any
-	Version uint64
+// countRange counts document IDs within a given range of values without
+// collecting them.
+func (fi *fieldIndex) countRange(minValues, maxValues []any) int {
+	if fi.explode != nil {
+		return len(fi.lookupRange(minValues, maxValues)) // Count each document once
+	}
+
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+
+	count := 0
+	minEntry := indexEntry{key: fi.key(fi.transformValues(minValues))}
+	maxEntry := indexEntry{key: fi.key(fi.transformValues(maxValues))}
+
+	fi.tree.AscendRange(minEntry, maxEntry, func(item btree.Item) bool {
+		count += len(item.(indexEntry).docIDs)
+		return true
+	})
+
+	return count
 }
 
-// DocumentStream provides an iterator-like interface for streaming documents.
-type DocumentStream struct {
-	results chan DocumentResult
-	errors  chan error
-	ctx     context.Context
-	cancel  context.CancelFunc
+// keyInRange reports whether data is indexed under any key within
+// [minValues, maxValues), the range countRange and lookupRange cover.
+func (fi *fieldIndex) keyInRange(data map[string]any, minValues, maxValues []any) bool {
+	minKey := fi.key(fi.transformValues(minValues))
+	maxKey := fi.key(fi.transformValues(maxValues))
+	return slices.ContainsFunc(fi.extractKeys(data), func(values []any) bool {
+		key := fi.key(values)
+		return !key.Less(minKey) && key.Less(maxKey)
+	})
 }
 
-// NewDocumentStream creates a new document stream with the specified buffer size.
-func NewDocumentStream(bufferSize int) *DocumentStream {
-	ctx, cancel := context.WithCancel(context.Background())
+// lookupOpen returns document IDs in [minValues, maxValues), treating a nil or
+// empty bound as unbounded on that side.
+func (fi *fieldIndex) lookupOpen(minValues, maxValues []any) []string {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
 
-	var results chan DocumentResult
-	if bufferSize > 0 {
-		results = make(chan DocumentResult, bufferSize)
-	} else {
-		results = make(chan DocumentResult)
+	var result []string
+	collect := func(item btree.Item) bool {
+		result = item.(indexEntry).appendDocIDs(result)
+		return true
 	}
 
-	err := make(chan error, 1)
-
-	return &DocumentStream{
-		ctx:     ctx,
-		cancel:  cancel,
-		results: results,
-		errors:  err,
+	minEntry := indexEntry{key: fi.key(fi.transformValues(minValues))}
+	maxEntry := indexEntry{key: fi.key(fi.transformValues(maxValues))}
+	switch {
+	case len(minValues) == 0 && len(maxValues) == 0:
+		fi.tree.Ascend(collect)
+	case len(minValues) == 0:
+		fi.tree.AscendLessThan(maxEntry, collect)
+	case len(maxValues) == 0:
+		fi.tree.AscendGreaterOrEqual(minEntry, collect)
+	default:
+		fi.tree.AscendRange(minEntry, maxEntry, collect)
 	}
+
+	return fi.uniqueIDs(result)
 }
 
-// Next returns the next document from the stream.
-func (ds *DocumentStream) Next() (DocumentResult, error) {
-	select {
-	case result, ok := <-ds.results:
-		if !ok {
-			return DocumentResult{}, ErrStreamClosed
-		}
-		return result, nil
+// lookupBounds finds document IDs between two bounds, each of which may be
+// inclusive or exclusive.
+func (fi *fieldIndex) lookupBounds(minValues, maxValues []any, minInclusive, maxInclusive bool) []string {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
 
-	case err, ok := <-ds.errors:
-		if !ok {
-			// Error channel closed, check if there are remaining results
-			select {
-			case result, ok := <-ds.results:
-				if ok {
-					return result, nil
-				}
-			default:
-			}
-			return DocumentResult{}, ErrStreamClosed
+	minValues = fi.transformValues(minValues)
+	maxValues = fi.transformValues(maxValues)
+
+	var result []string
+	fi.tree.AscendGreaterOrEqual(indexEntry{key: fi.key(minValues)}, func(item btree.Item) bool {
+		entry := item.(indexEntry)
+		if c := fi.compareKeys(entry.key.values, maxValues); c > 0 || (c == 0 && !maxInclusive) {
+			return false // Past the maximum
 		}
-		return DocumentResult{}, err
+		if !minInclusive && fi.compareKeys(entry.key.values, minValues) == 0 {
+			return true // Skip the excluded minimum
+		}
+		result = entry.appendDocIDs(result)
+		return true
+	})
 
-	case <-ds.ctx.Done():
-		return DocumentResult{}, ds.ctx.Err()
-	}
+	return fi.uniqueIDs(result)
 }
 
-// Close cancels the stream and releases resources.
-func (ds *DocumentStream) Close() {
-	ds.cancel()
-}
+// lookupRangeNullsLast finds document IDs within a range like lookupRange, but
+// treats nil bound components as sorting after every value.
+func (fi *fieldIndex) lookupRangeNullsLast(minValues, maxValues []any) []string {
+	if fi.cmp != nil {
+		return fi.lookupRange(minValues, maxValues) // The comparator decides where nil sorts
+	}
 
-// Store is an in-memory document database with indexing capabilities.
-type Store struct {
-	collection *Collection
-	handles    map[string]HandleEntry // Centralized handle management
-	indexes    map[string]*fieldIndex // Maps index name to index
-	mu         sync.RWMutex           // Protects handles and indexes maps
-	version    uint64                 // Global version counter
-	closed     atomic.Bool            // Indicates if store is closed
-}
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
 
-// NewStore creates a new, empty document store.
-func NewStore() *Store {
-	collection := NewCollection()
-	return &Store{
-		collection: collection,
-		handles:    make(map[string]HandleEntry),
-		indexes:    make(map[string]*fieldIndex),
+	minValues = fi.transformValues(minValues)
+	maxValues = fi.transformValues(maxValues)
+
+	// Keys never contain nil, so they order the same either way. Start at the
+	// part of the minimum before its first nil, which precedes every key the
+	// full minimum admits, and skip ahead from there.
+	start := minValues
+	if i := slices.Index(minValues, nil); i >= 0 {
+		start = minValues[:i]
 	}
+
+	var result []string
+	fi.tree.AscendGreaterOrEqual(indexEntry{key: fi.key(start)}, func(item btree.Item) bool {
+		entry := item.(indexEntry)
+		if compareKeyToBound(entry.key.values, maxValues, true) >= 0 {
+			return false // Past the maximum
+		}
+		if compareKeyToBound(entry.key.values, minValues, true) >= 0 {
+			result = entry.appendDocIDs(result)
+		}
+		return true
+	})
+
+	return fi.uniqueIDs(result)
 }
 
-// Insert adds a new document to the store and updates all indexes.
-func (s *Store) Insert(doc map[string]any) (string, error) {
-	if s.closed.Load() {
-		return "", ErrStoreClosed
+// compareKeyToBound compares an index key with a range bound the way
+// indexKey.Less orders keys, except that when nullsLast is set a nil bound
+// component sorts after every value instead of before.
+func compareKeyToBound(key, bound []any, nullsLast bool) int {
+	for i := range min(len(key), len(bound)) {
+		if nullsLast && bound[i] == nil {
+			return -1
+		}
+		if c := compareValues(key[i], bound[i]); c != 0 {
+			return c
+		}
 	}
+	return cmp.Compare(len(key), len(bound))
+}
 
-	if doc == nil {
-		return "", ErrInvalidDocument
-	}
+// lookupPrefix finds document IDs whose keys start with the given values.
+// An empty prefix matches every document in the index.
+func (fi *fieldIndex) lookupPrefix(prefixValues []any) []string {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
 
-	// Generate unique ID and version
-	docID := uuid.Must(uuid.NewV7()).String()
-	version := atomic.AddUint64(&s.version, 1)
+	var result []string
+	prefixValues = fi.transformValues(prefixValues)
+	startEntry := indexEntry{key: fi.key(prefixValues)}
 
-	// Insert into collection to get stable index
-	index := s.collection.Insert(docID, doc, version)
+	fi.tree.AscendGreaterOrEqual(startEntry, func(item btree.Item) bool {
+		entry := item.(indexEntry)
+		if !fi.hasKeyPrefix(entry.key.values, prefixValues) {
+			return false // Past the prefix range
+		}
+		result = entry.appendDocIDs(result)
+		return true
+	})
+
+	return fi.uniqueIDs(result)
+}
+
+// extreme returns the IDs stored under the smallest key, or the largest when
+// largest is set. It returns nil for an empty index.
+func (fi *fieldIndex) extreme(largest bool, keep func(docID string) bool) []string {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+
+	walk := fi.tree.Ascend
+	if largest {
+		walk = fi.tree.Descend
+	}
+
+	// Keys whose documents are all filtered out are passed over
+	var docIDs []string
+	walk(func(item btree.Item) bool {
+		docIDs = item.(indexEntry).appendDocIDs(docIDs[:0])
+		if keep != nil {
+			docIDs = slices.DeleteFunc(docIDs, func(docID string) bool { return !keep(docID) })
+		}
+		return len(docIDs) == 0
+	})
+
+	return docIDs
+}
+
+// distinctKeys returns a copy of every key in the index in ascending order,
+// leaving out keys none of whose documents satisfy keep. A nil keep keeps
+// every document.
+func (fi *fieldIndex) distinctKeys(keep func(docID string) bool) [][]any {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+
+	keys := make([][]any, 0, fi.tree.Len())
+	fi.tree.Ascend(func(item btree.Item) bool {
+		entry := item.(indexEntry)
+		if entry.countKept(keep) > 0 {
+			keys = append(keys, copyKey(entry.key.values))
+		}
+		return true
+	})
+
+	return keys
+}
+
+// keyGroup is the set of documents sharing one key in an index.
+type keyGroup struct {
+	key    []any
+	docIDs []string
+}
+
+// groups returns the index's keys in order, each with its document IDs.
+func (fi *fieldIndex) groups() []keyGroup {
+	return fi.walkGroups(fi.tree.Ascend)
+}
+
+// descendingGroups is like groups but returns the keys in descending order.
+func (fi *fieldIndex) descendingGroups() []keyGroup {
+	return fi.walkGroups(fi.tree.Descend)
+}
+
+// walkGroups collects a key group for every entry visited by walk.
+func (fi *fieldIndex) walkGroups(walk func(btree.ItemIterator)) []keyGroup {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+
+	groups := make([]keyGroup, 0, fi.tree.Len())
+	walk(func(item btree.Item) bool {
+		entry := item.(indexEntry)
+		groups = append(groups, keyGroup{
+			key:    entry.key.values,
+			docIDs: entry.appendDocIDs(nil),
+		})
+		return true
+	})
+
+	return groups
+}
+
+// coveredLookup returns a copy of the key matching values once for every
+// document under it.
+func (fi *fieldIndex) coveredLookup(values []any, keep func(docID string) bool) [][]any {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+
+	searchEntry := indexEntry{key: fi.key(fi.transformValues(values))}
+	item := fi.tree.Get(searchEntry)
+	if item == nil {
+		return nil
+	}
+
+	return item.(indexEntry).appendKeys(nil, keep)
+}
+
+// coveredRange returns a copy of every key between minValues and maxValues,
+// once for every document under it, in ascending key order.
+func (fi *fieldIndex) coveredRange(minValues, maxValues []any, keep func(docID string) bool) [][]any {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+
+	var keys [][]any
+	minEntry := indexEntry{key: fi.key(fi.transformValues(minValues))}
+	maxEntry := indexEntry{key: fi.key(fi.transformValues(maxValues))}
+
+	fi.tree.AscendRange(minEntry, maxEntry, func(item btree.Item) bool {
+		keys = item.(indexEntry).appendKeys(keys, keep)
+		return true
+	})
+
+	return keys
+}
+
+// appendKeys appends a copy of the entry's key to dst once per document that
+// satisfies keep, or once per document when keep is nil.
+func (ie indexEntry) appendKeys(dst [][]any, keep func(docID string) bool) [][]any {
+	for range ie.countKept(keep) {
+		dst = append(dst, copyKey(ie.key.values))
+	}
+	return dst
+}
+
+// countKept counts the entry's documents that satisfy keep, or all of them
+// when keep is nil.
+func (ie indexEntry) countKept(keep func(docID string) bool) int {
+	if keep == nil {
+		return len(ie.docIDs)
+	}
+
+	kept := 0
+	for docID := range ie.docIDs {
+		if keep(docID) {
+			kept++
+		}
+	}
+	return kept
+}
+
+// copyKey deep-copies an index key so callers can't modify the index.
+func copyKey(values []any) []any {
+	key := make([]any, len(values))
+	for i, value := range values {
+		key[i] = copyValue(value)
+	}
+	return key
+}
+
+// hasKeyPrefix reports whether key begins with prefix.
+func (fi *fieldIndex) hasKeyPrefix(key, prefix []any) bool {
+	if len(key) < len(prefix) {
+		return false
+	}
+	if fi.cmp != nil {
+		return fi.cmp(key[:len(prefix)], prefix) == 0
+	}
+	for i, value := range prefix {
+		if compareValues(key[i], value) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// stats summarizes the shape of the index.
+func (fi *fieldIndex) stats() map[string]any {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+
+	entries := fi.tree.Len()
+	totalDocs, maxDocs := 0, 0
+	fi.tree.Ascend(func(item btree.Item) bool {
+		count := len(item.(indexEntry).docIDs)
+		totalDocs += count
+		maxDocs = max(maxDocs, count)
+		return true
+	})
+
+	avgDocs := 0.0
+	if entries > 0 {
+		avgDocs = float64(totalDocs) / float64(entries)
+	}
+
+	return map[string]any{
+		"entries":            entries,
+		"total_docs":         totalDocs,
+		"fields":             slices.Clone(fi.fields),
+		"avg_docs_per_entry": avgDocs,
+		"max_docs_per_entry": maxDocs,
+	}
+}
+
+// KeyEqualFunc reports whether two extracted index keys are equivalent.
+// Either key may be nil when the document lacks an indexed field.
+type KeyEqualFunc func(a, b []any) bool
+
+// NumericKeyEqual is the default KeyEqualFunc. It compares keys element by
+// element with the same ordering the B-tree uses, so numerically equal values
+// of different types (such as 5 and 5.0) are considered the same key.
+func NumericKeyEqual(a, b []any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if compareValues(a[i], b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// DocumentResult holds the data and metadata for a document returned from a query.
+type DocumentResult struct {
+	ID        string
+	Data      map[string]any
+	Version   uint64
+	CreatedAt time.Time // When the document was inserted
+	UpdatedAt time.Time // When the document was last written; equals CreatedAt until the first update
+	Deleted   bool      // Marks a deleted document in StreamChanges; Data is nil
+}
+
+// documentResultJSON is the stable wire format for DocumentResult.
+type documentResultJSON struct {
+	ID        string         `json:"id"`
+	Version   uint64         `json:"version"`
+	CreatedAt time.Time      `json:"created_at,omitzero"`
+	UpdatedAt time.Time      `json:"updated_at,omitzero"`
+	Data      map[string]any `json:"data"`
+	Deleted   bool           `json:"deleted,omitempty"`
+}
+
+// MarshalJSON encodes the result as {"id": ..., "version": ..., "data": {...}},
+// plus RFC 3339 "created_at" and "updated_at" fields when they are set.
+func (dr DocumentResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(documentResultJSON{
+		ID:        dr.ID,
+		Version:   dr.Version,
+		CreatedAt: dr.CreatedAt,
+		UpdatedAt: dr.UpdatedAt,
+		Data:      dr.Data,
+		Deleted:   dr.Deleted,
+	})
+}
+
+// UnmarshalJSON decodes the format produced by MarshalJSON.
+func (dr *DocumentResult) UnmarshalJSON(data []byte) error {
+	var wire documentResultJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	dr.ID = wire.ID
+	dr.Version = wire.Version
+	dr.CreatedAt = wire.CreatedAt
+	dr.UpdatedAt = wire.UpdatedAt
+	dr.Data = wire.Data
+	dr.Deleted = wire.Deleted
+	return nil
+}
+
+// DocumentStream provides an iterator-like interface for streaming documents.
+type DocumentStream struct {
+	results chan DocumentResult
+	errors  chan error
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewDocumentStream creates a new document stream with the specified buffer size.
+func NewDocumentStream(bufferSize int) *DocumentStream {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var results chan DocumentResult
+	if bufferSize > 0 {
+		results = make(chan DocumentResult, bufferSize)
+	} else {
+		results = make(chan DocumentResult)
+	}
+
+	err := make(chan error, 1)
+
+	return &DocumentStream{
+		ctx:     ctx,
+		cancel:  cancel,
+		results: results,
+		errors:  err,
+	}
+}
+
+// Next returns the next document from the stream.
+func (ds *DocumentStream) Next() (DocumentResult, error) {
+	select {
+	case result, ok := <-ds.results:
+		if !ok {
+			return DocumentResult{}, ErrStreamClosed
+		}
+		return result, nil
+
+	case err, ok := <-ds.errors:
+		if !ok {
+			// Error channel closed, check if there are remaining results
+			select {
+			case result, ok := <-ds.results:
+				if ok {
+					return result, nil
+				}
+			default:
+			}
+			return DocumentResult{}, ErrStreamClosed
+		}
+		return DocumentResult{}, err
+
+	case <-ds.ctx.Done():
+		return DocumentResult{}, ds.ctx.Err()
+	}
+}
+
+// Close cancels the stream and releases resources.
+func (ds *DocumentStream) Close() {
+	ds.cancel()
+}
+
+// Store is an in-memory document database with indexing capabilities.
+type Store struct {
+	collection     *Collection
+	handles        map[string]HandleEntry           // Centralized handle management
+	readHandles    sync.Map                         // Mirrors the handles in handles so Get can skip mu
+	indexes        map[string]*fieldIndex           // Maps index name to index
+	mu             sync.RWMutex                     // Protects handles and indexes maps
+	version        uint64                           // Global version counter
+	closed         atomic.Bool                      // Indicates if store is closed
+	keyEqual       KeyEqualFunc                     // Decides whether an update moved an index key
+	newID          func() string                    // Generates IDs for inserted documents
+	metrics        atomic.Pointer[metricsHook]      // Optional operation latency hook
+	indexHook      atomic.Pointer[func(IndexEvent)] // Optional index membership observer
+	validator      func(map[string]any) error       // Optional check run before every write
+	maxDocBytes    int64                            // Estimated size limit per document; 0 disables it
+	tombstones     []tombstone                      // Recent deletes for StreamChanges, in version order
+	tombstoneFloor uint64                           // Version of the newest tombstone dropped from tombstones
+	expiring       map[string]struct{}              // IDs of documents with a TTL
+	trash          map[string]*DocumentHandle       // Soft-deleted documents awaiting restore or purge
+	lru            atomic.Pointer[lruTracker]       // Access order for eviction; nil when eviction is disabled
+	maxDocuments   int                              // Document cap enforced by eviction
+	evictions      atomic.Uint64                    // Number of documents evicted
+	sweepStop      chan struct{}                    // Closed to stop the TTL sweeper
+	sweepDone      chan struct{}                    // Closed once the TTL sweeper has exited
+	watchers       map[uint64]chan ChangeEvent
+	watchMu        sync.Mutex // Protects watchers and nextWatch
+	nextWatch      uint64
+}
+
+// NewStore creates a new, empty document store.
+func NewStore() *Store {
+	return newStore(defaultSweepInterval)
+}
+
+// newStore creates a new, empty document store whose TTL sweeper runs at the
+// given interval.
+func newStore(sweepInterval time.Duration) *Store {
+	collection := NewCollection()
+	s := &Store{
+		collection: collection,
+		handles:    make(map[string]HandleEntry),
+		indexes:    make(map[string]*fieldIndex),
+		keyEqual:   NumericKeyEqual,
+		newID:      newUUID,
+		expiring:   make(map[string]struct{}),
+		trash:      make(map[string]*DocumentHandle),
+		sweepStop:  make(chan struct{}),
+		sweepDone:  make(chan struct{}),
+		watchers:   make(map[uint64]chan ChangeEvent),
+	}
+
+	go s.sweepExpired(sweepInterval)
+	return s
+}
+
+// SetKeyEquality replaces the function Update uses to decide whether a
+// document's index key changed. When it reports the old and new keys as equal
+// the index entry is left untouched. Passing nil restores NumericKeyEqual.
+func (s *Store) SetKeyEquality(fn KeyEqualFunc) {
+	if s == nil {
+		return
+	}
+
+	if fn == nil {
+		fn = NumericKeyEqual
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyEqual = fn
+}
+
+// newUUID is the default ID generator. UUIDv7 IDs sort by creation time.
+func newUUID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// SetIDGenerator replaces the function that generates IDs for inserted
+// documents, for example to use monotonic integers. It is called with the
+// store locked, so it must not call back into the store. An insert whose
+// generated ID is already taken fails with ErrIDExists. Passing nil restores
+// the default UUIDv7 generator.
+func (s *Store) SetIDGenerator(fn func() string) {
+	if s == nil {
+		return
+	}
+
+	if fn == nil {
+		fn = newUUID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.newID = fn
+}
+
+// setHandle records the handle entry for a document whose handle is new or has
+// changed. Writes that only change index membership may assign to s.handles
+// directly. The caller must hold s.mu for writing.
+func (s *Store) setHandle(docID string, entry HandleEntry) {
+	s.handles[docID] = entry
+	s.readHandles.Store(docID, entry.handle)
+}
+
+// removeHandle forgets a document's handle. The caller must hold s.mu for
+// writing.
+func (s *Store) removeHandle(docID string) {
+	delete(s.handles, docID)
+	s.readHandles.Delete(docID)
+}
+
+// idTaken reports whether docID belongs to a live or soft-deleted document.
+// The caller must hold s.mu.
+func (s *Store) idTaken(docID string) bool {
+	if _, exists := s.handles[docID]; exists {
+		return true
+	}
+	_, exists := s.trash[docID]
+	return exists
+}
+
+// Insert adds a new document to the store and updates all indexes. Like the
+// store's other methods, it reports ErrStoreNil when called on a nil *Store.
+func (s *Store) Insert(doc map[string]any) (string, error) {
+	defer s.observe(time.Now(), MetricsHook.OnInsert)
+
+	if err := s.ensureOpen(); err != nil {
+		return "", err
+	}
+
+	if doc == nil {
+		return "", ErrInvalidDocument
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.validateDocument(doc); err != nil {
+		return "", err
+	}
+
+	// Generate unique ID
+	docID := s.newID()
+	if s.idTaken(docID) {
+		return "", ErrIDExists
+	}
+
+	s.insertLocked(docID, doc)
+
+	return docID, nil
+}
+
+// InsertIfAbsent inserts doc only if no live document matches values exactly
+// on the named index, for idempotent ingestion. If one does, nothing is
+// written and its ID is returned with inserted false; when several match, the
+// lowest ID is returned. Unlike an upsert it never updates. The lookup and
+// insert happen under one lock, so concurrent calls with the same key insert
+// at most one document.
+func (s *Store) InsertIfAbsent(indexName string, values []any, doc map[string]any) (id string, inserted bool, err error) {
+	defer s.observe(time.Now(), MetricsHook.OnInsert)
+
+	if err := s.ensureOpen(); err != nil {
+		return "", false, err
+	}
+
+	if doc == nil {
+		return "", false, ErrInvalidDocument
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.indexes[indexName]
+	if !exists {
+		return "", false, ErrIndexNotFound
+	}
+
+	now := time.Now()
+	for _, docID := range index.lookup(values) {
+		if entry, exists := s.handles[docID]; exists && !entry.handle.expired(now) {
+			return docID, false, nil
+		}
+	}
+
+	if err := s.validateDocument(doc); err != nil {
+		return "", false, err
+	}
+
+	docID := s.newID()
+	if s.idTaken(docID) {
+		return "", false, ErrIDExists
+	}
+
+	s.insertLocked(docID, doc)
+	return docID, true, nil
+}
+
+// InsertWithID adds a new document under a caller-supplied ID, such as a key
+// carried over from another system. It fails with ErrIDExists if a live or
+// soft-deleted document already has that ID.
+func (s *Store) InsertWithID(docID string, doc map[string]any) error {
+	defer s.observe(time.Now(), MetricsHook.OnInsert)
+
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	if docID == "" || doc == nil {
+		return ErrInvalidDocument
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.idTaken(docID) {
+		return ErrIDExists
+	}
+
+	if err := s.validateDocument(doc); err != nil {
+		return err
+	}
+
+	s.insertLocked(docID, doc)
+	return nil
+}
+
+// validateDocument reports why doc may not be written: a size over the
+// store's limit, a value a strict typed index would reject, or an error from
+// the store's validator, which receives a copy. The caller must hold s.mu.
+func (s *Store) validateDocument(doc map[string]any) error {
+	if s.maxDocBytes > 0 {
+		if size := estimateValueSize(doc); size > s.maxDocBytes {
+			return fmt.Errorf("%w: %d bytes, limit %d", ErrDocumentTooLarge, size, s.maxDocBytes)
+		}
+	}
+
+	for _, index := range s.indexes {
+		if !index.strict {
+			continue
+		}
+		if err := index.typeError(doc); err != nil {
+			return err
+		}
+	}
+
+	if s.validator != nil {
+		return s.validator(copyDocument(doc))
+	}
+	return nil
+}
+
+// SetValidator installs fn to check every document before it is written by an
+// insert, update or patch, or brought back by Restore; a non-nil error aborts
+// the write and is returned to the caller unchanged. For a patch fn sees the
+// merged document. fn receives a copy, so it cannot alter what is stored. It
+// runs with the store locked, so it must not call back into the store, and it
+// should be free of side effects, as batch writes and transactions may check
+// a document more than once. Passing nil removes the validator.
+func (s *Store) SetValidator(fn func(map[string]any) error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validator = fn
+}
+
+// SetMaxDocumentBytes limits the size of every document written by an insert,
+// update or patch, or brought back by Restore, to n bytes; larger documents
+// are rejected with ErrDocumentTooLarge. Sizes are estimated by walking the
+// document the same way EstimateMemory does, so they approximate the memory
+// a document holds rather than its JSON length. Documents already stored are
+// not checked. A limit of zero or less disables the check, which is the
+// default.
+func (s *Store) SetMaxDocumentBytes(n int) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxDocBytes = int64(max(n, 0))
+}
+
+// InsertBatch adds multiple documents under a single lock acquisition and
+// returns their generated IDs in input order. All documents are validated
+// before any is written, so a nil document rejects the whole batch. Under a
+// document cap, room is made by evicting existing documents before the batch
+// is written, so none of the returned IDs is evicted by the batch itself; a
+// batch larger than the cap fails with ErrBatchTooLarge.
+func (s *Store) InsertBatch(docs []map[string]any) ([]string, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		if doc == nil {
+			return nil, ErrInvalidDocument
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lru.Load() != nil && len(docs) > s.maxDocuments {
+		return nil, ErrBatchTooLarge
+	}
+
+	for _, doc := range docs {
+		if err := s.validateDocument(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]string, len(docs))
+	seen := make(map[string]struct{}, len(docs))
+	for i := range docs {
+		ids[i] = s.newID()
+		if _, duplicate := seen[ids[i]]; duplicate || s.idTaken(ids[i]) {
+			return nil, ErrIDExists
+		}
+		seen[ids[i]] = struct{}{}
+	}
+
+	s.makeRoom(len(docs))
+
+	// Write every document to the collection first, keeping one private copy
+	// of each for key extraction instead of re-reading per index
+	handles := make([]*DocumentHandle, len(docs))
+	data := make([]map[string]any, len(docs))
+	entries := make([]HandleEntry, len(docs))
+	versions := make([]uint64, len(docs))
+	for i, doc := range docs {
+		versions[i] = atomic.AddUint64(&s.version, 1)
+		data[i] = copyDocument(doc)
+		handles[i] = &DocumentHandle{
+			id:    ids[i],
+			index: s.collection.Insert(ids[i], data[i], versions[i]),
+		}
+		entries[i] = HandleEntry{
+			handle:  handles[i],
+			indexes: make([]string, 0, len(s.indexes)),
+		}
+	}
+
+	// Then update each index once for the whole batch
+	for idxName, idx := range s.indexes {
+		for i, indexed := range idx.insertBatch(ids, data) {
+			if indexed {
+				entries[i].indexes = append(entries[i].indexes, idxName)
+			}
+		}
+	}
+
+	for i, entry := range entries {
+		s.setHandle(ids[i], entry)
+		s.broadcast(ChangeEvent{Type: ChangeInsert, ID: ids[i], Version: versions[i]})
+		s.trackInsert(ids[i])
+	}
+
+	return ids, nil
+}
+
+// insertLocked stores a document under docID and adds it to every index.
+// The caller must hold s.mu for writing.
+func (s *Store) insertLocked(docID string, doc map[string]any) *DocumentHandle {
+	version := atomic.AddUint64(&s.version, 1)
+
+	// Insert into collection to get stable index
+	index := s.collection.Insert(docID, doc, version)
+
+	// Create handle
+	handle := &DocumentHandle{
+		id:    docID,
+		index: index,
+	}
+
+	// Create handle entry
+	entry := HandleEntry{
+		handle:  handle,
+		indexes: make([]string, 0, len(s.indexes)),
+	}
+
+	// Update all indexes synchronously
+	for idxName, idx := range s.indexes {
+		if idx.insertDocument(handle) {
+			entry.indexes = append(entry.indexes, idxName)
+		}
+	}
+
+	// Add handle entry to store
+	s.setHandle(docID, entry)
+	s.broadcast(ChangeEvent{Type: ChangeInsert, ID: docID, Version: version})
+
+	s.trackInsert(docID)
+	s.evictOverflow()
+
+	return handle
+}
+
+// ImportWithConflict inserts docs, using uniqueIndex to detect documents that
+// already exist. When an incoming document's key matches an existing one,
+// resolve receives copies of both and returns the document to store and
+// whether to update at all; otherwise the incoming document is inserted.
+// Documents lacking the indexed fields are always inserted. If several stored
+// documents share the key, the one with the lowest ID is used. Each document
+// is resolved and written atomically, so duplicates within docs are resolved
+// against each other. resolve runs with the store locked and must not call
+// back into the store.
+func (s *Store) ImportWithConflict(docs []map[string]any, uniqueIndex string, resolve func(existing, incoming map[string]any) (map[string]any, bool)) (inserted, updated int, err error) {
+	if err := s.ensureOpen(); err != nil {
+		return 0, 0, err
+	}
+
+	for _, doc := range docs {
+		if doc == nil {
+			return 0, 0, ErrInvalidDocument
+		}
+	}
+
+	for _, doc := range docs {
+		wrote, isUpdate, err := s.importWithConflict(doc, uniqueIndex, resolve)
+		if err != nil {
+			return inserted, updated, err
+		}
+		switch {
+		case wrote && isUpdate:
+			updated++
+		case wrote:
+			inserted++
+		}
+	}
+
+	return inserted, updated, nil
+}
+
+// importWithConflict inserts or resolves a single document for
+// ImportWithConflict, reporting whether anything was written and whether it
+// was an update.
+func (s *Store) importWithConflict(doc map[string]any, uniqueIndex string, resolve func(existing, incoming map[string]any) (map[string]any, bool)) (wrote, isUpdate bool, err error) {
+	if err := s.ensureOpen(); err != nil {
+		return false, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.indexes[uniqueIndex]
+	if !exists {
+		return false, false, ErrIndexNotFound
+	}
+
+	var existingIDs []string
+	for _, key := range index.extractKeys(doc) {
+		existingIDs = append(existingIDs, index.lookup(key)...)
+	}
+	existingIDs = index.uniqueIDs(existingIDs)
+
+	if len(existingIDs) == 0 {
+		if err := s.validateDocument(doc); err != nil {
+			return false, false, err
+		}
+		docID := s.newID()
+		if s.idTaken(docID) {
+			return false, false, ErrIDExists
+		}
+		s.insertLocked(docID, doc)
+		return true, false, nil
+	}
+
+	existingID := existingIDs[0]
+	existing, exists := s.collection.Get(s.handles[existingID].handle.index)
+	if !exists {
+		return false, false, ErrDocumentDeleted
+	}
+
+	merged, ok := resolve(existing.data, copyDocument(doc))
+	if !ok {
+		return false, true, nil
+	}
+	if merged == nil {
+		return false, true, ErrInvalidDocument
+	}
+
+	if err := s.updateLocked(existingID, merged); err != nil {
+		return false, true, err
+	}
+	return true, true, nil
+}
+
+// Update modifies an existing document and updates all affected indexes.
+func (s *Store) Update(docID string, doc map[string]any) error {
+	defer s.observe(time.Now(), MetricsHook.OnUpdate)
+
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	if doc == nil {
+		return ErrInvalidDocument
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateLocked(docID, doc)
+}
+
+// UpdateBatch replaces many documents under a single lock acquisition and
+// returns how many were updated. Every ID that exists is updated even when
+// others don't or fail; the missing IDs are reported, sorted, in an error
+// wrapping ErrDocumentNotFound, and any other failure is returned as it is,
+// joined with the rest so each can be matched with errors.Is. All documents
+// are validated before any is written, so a nil document rejects the whole
+// batch.
+func (s *Store) UpdateBatch(updates map[string]map[string]any) (int, error) {
+	if err := s.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	for _, doc := range updates {
+		if doc == nil {
+			return 0, ErrInvalidDocument
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, doc := range updates {
+		if err := s.validateDocument(doc); err != nil {
+			return 0, err
+		}
+	}
+
+	applied := 0
+	var missing []string
+	var failed []error
+	for _, docID := range slices.Sorted(maps.Keys(updates)) {
+		switch err := s.updateLocked(docID, updates[docID]); {
+		case err == nil:
+			applied++
+		case errors.Is(err, ErrDocumentNotFound):
+			missing = append(missing, docID)
+		default:
+			failed = append(failed, err)
+		}
+	}
+
+	if len(missing) > 0 {
+		failed = append([]error{fmt.Errorf("%w: %s", ErrDocumentNotFound, strings.Join(missing, ", "))}, failed...)
+	}
+
+	return applied, errors.Join(failed...)
+}
+
+// UpdateWhere rewrites every document satisfying pred with the result of
+// mutate and returns how many were updated. Each write bumps the document's
+// version and updates its indexes, which makes it suitable for migrations such
+// as adding a default field. Both functions receive a private copy of the
+// document, so mutating it in place and returning it is safe; returning nil
+// leaves that document unchanged. A write the store rejects, for example by
+// the validator, leaves that document unchanged without stopping the others;
+// each failure is returned, naming the document, joined with the rest so it
+// can be matched with errors.Is. Everything happens under one write lock, so
+// neither function may call back into the store.
+func (s *Store) UpdateWhere(pred func(map[string]any) bool, mutate func(map[string]any) map[string]any) (int, error) {
+	if err := s.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := 0
+	var failed []error
+	for _, doc := range s.collection.GetAllValid() {
+		if !pred(doc.data) {
+			continue
+		}
+
+		newData := mutate(copyDocument(doc.data))
+		if newData == nil {
+			continue
+		}
+
+		if err := s.updateLocked(doc.id, newData); err != nil {
+			failed = append(failed, fmt.Errorf("document %s: %w", doc.id, err))
+			continue
+		}
+		updated++
+	}
+
+	return updated, errors.Join(failed...)
+}
+
+// deleteFieldMarker is the type of the DeleteField sentinel.
+type deleteFieldMarker struct{}
+
+// DeleteField is a sentinel value for Patch: a key mapped to DeleteField is
+// removed from the document instead of being set.
+var DeleteField any = deleteFieldMarker{}
+
+// Patch overlays fields onto an existing document, leaving keys that are not
+// mentioned untouched. Keys whose value is DeleteField are removed. The
+// read-modify-write happens atomically and bumps the document's version.
+func (s *Store) Patch(docID string, fields map[string]any) error {
+	defer s.observe(time.Now(), MetricsHook.OnUpdate)
+
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	if fields == nil {
+		return ErrInvalidDocument
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.handles[docID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+
+	doc, exists := s.collection.Get(entry.handle.index)
+	if !exists {
+		return ErrDocumentDeleted
+	}
+
+	for key, value := range fields {
+		if _, remove := value.(deleteFieldMarker); remove {
+			delete(doc.data, key)
+		} else {
+			doc.data[key] = value
+		}
+	}
+
+	return s.updateLocked(docID, doc.data)
+}
+
+// UnsetFields removes the named keys from an existing document and bumps its
+// version. The document drops out of any index that relied on a removed
+// field; other indexes are left untouched.
+func (s *Store) UnsetFields(docID string, fields []string) error {
+	patch := make(map[string]any, len(fields))
+	for _, field := range fields {
+		patch[field] = DeleteField
+	}
+	return s.Patch(docID, patch)
+}
+
+// CompareAndSetField atomically sets field to newValue only if its current
+// value equals expected according to the index ordering, and reports whether
+// the swap happened. A missing field matches an expected value of nil.
+func (s *Store) CompareAndSetField(docID, field string, expected, newValue any) (bool, error) {
+	if err := s.ensureOpen(); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.handles[docID]
+	if !exists {
+		return false, ErrDocumentNotFound
+	}
+
+	doc, exists := s.collection.Get(entry.handle.index)
+	if !exists {
+		return false, ErrDocumentDeleted
+	}
+
+	current, present := doc.data[field]
+	if (present && compareValues(current, expected) != 0) || (!present && expected != nil) {
+		return false, nil
+	}
+
+	doc.data[field] = newValue
+	if err := s.updateLocked(docID, doc.data); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// updateLocked replaces a document's data and moves it between index keys as
+// needed. The caller must hold s.mu for writing.
+func (s *Store) updateLocked(docID string, doc map[string]any) error {
+	entry, exists := s.handles[docID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+
+	// Get old data for index updates
+	currentDoc, exists := s.collection.Get(entry.handle.index)
+	if !exists {
+		return ErrDocumentDeleted
+	}
+
+	if err := s.validateDocument(doc); err != nil {
+		return err
+	}
+
+	currentData := copyDocument(currentDoc.data)
+
+	// Update in collection
+	version := atomic.AddUint64(&s.version, 1)
+	if !s.collection.Update(entry.handle.index, doc, version) {
+		return ErrDocumentDeleted
+	}
+
+	// Update indexes and track new membership
+	newIndexes := make([]string, 0, len(s.indexes))
+	for idxName, idx := range s.indexes {
+		if idx.updateDocument(entry.handle, currentData, s.keyEqual) {
+			newIndexes = append(newIndexes, idxName)
+		}
+	}
+
+	// Update handle entry with new index membership
+	entry.indexes = newIndexes
+	s.handles[docID] = entry
+	s.broadcast(ChangeEvent{Type: ChangeUpdate, ID: docID, Version: version})
+
+	return nil
+}
+
+// Delete removes a document from the store and all indexes.
+func (s *Store) Delete(docID string) error {
+	defer s.observe(time.Now(), MetricsHook.OnDelete)
+
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteLocked(docID)
+}
+
+// DeleteIfVersion deletes a document only if its current version equals
+// expectedVersion, returning ErrVersionConflict if it has been written since
+// the caller read it. The check and the delete happen under one lock.
+func (s *Store) DeleteIfVersion(docID string, expectedVersion uint64) error {
+	defer s.observe(time.Now(), MetricsHook.OnDelete)
+
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.handles[docID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+
+	doc, exists := s.collection.Get(entry.handle.index)
+	if !exists {
+		return ErrDocumentDeleted
+	}
+
+	if doc.version != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	return s.deleteLocked(docID)
+}
+
+// DeleteBatch removes multiple documents under a single lock acquisition and
+// returns how many were actually deleted. IDs that don't exist are skipped.
+func (s *Store) DeleteBatch(ids []string) (int, error) {
+	if err := s.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, docID := range ids {
+		if s.deleteLocked(docID) == nil {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// DeleteByIndex removes every document exactly matching values on an index and
+// returns how many were deleted. The lookup and deletions happen under one
+// write lock, so no concurrent writer can slip in between them.
+func (s *Store) DeleteByIndex(indexName string, values []any) (int, error) {
+	if err := s.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.indexes[indexName]
+	if !exists {
+		return 0, ErrIndexNotFound
+	}
+
+	deleted := 0
+	for _, docID := range index.lookup(values) {
+		if s.deleteLocked(docID) == nil {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// DeleteWhere removes every document satisfying pred and returns how many
+// were deleted. No index is needed: all documents are scanned. Matching IDs
+// are collected first and deleted afterwards, all under one write lock, so the
+// scan never observes a half-deleted collection. pred receives a copy of each
+// document and must not call back into the store.
+func (s *Store) DeleteWhere(pred func(map[string]any) bool) (int, error) {
+	if err := s.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matches []string
+	for _, doc := range s.collection.GetAllValid() {
+		if pred(doc.data) {
+			matches = append(matches, doc.id)
+		}
+	}
+
+	deleted := 0
+	for _, docID := range matches {
+		if s.deleteLocked(docID) == nil {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// deleteLocked removes a document from the collection, its indexes and the
+// handle map. The caller must hold s.mu for writing.
+func (s *Store) deleteLocked(docID string) error {
+	entry, exists := s.handles[docID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+
+	// Get document data for index cleanup
+	doc, exists := s.collection.Get(entry.handle.index)
+	if !exists {
+		return ErrDocumentDeleted
+	}
+	docData := copyDocument(doc.data)
+
+	// Remove from only the indexes this document is actually in
+	for _, indexName := range entry.indexes {
+		if idx, exists := s.indexes[indexName]; exists {
+			idx.deleteDocument(docID, docData)
+		}
+	}
+
+	// Remove from collection and handles
+	s.collection.Delete(entry.handle.index)
+	s.removeHandle(docID)
+	delete(s.expiring, docID)
+	s.trackRemove(docID)
+	s.recordTombstone(docID)
+	s.broadcast(ChangeEvent{Type: ChangeDelete, ID: docID, Version: doc.version})
+
+	return nil
+}
+
+// Truncate removes every document, including soft-deleted ones, while keeping
+// the index definitions, which are left empty. It is much faster than deleting
+// documents one by one. The version counter is not reset, so versions issued
+// after a truncate never repeat earlier ones. Watchers receive a delete event
+// for each removed live document.
+func (s *Store) Truncate() error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for docID, entry := range s.handles {
+		if version, exists := s.collection.version(entry.handle.index); exists {
+			s.broadcast(ChangeEvent{Type: ChangeDelete, ID: docID, Version: version})
+		}
+		s.recordTombstone(docID)
+	}
+
+	for _, index := range s.indexes {
+		index.mu.Lock()
+		index.tree.Clear(false)
+		index.mu.Unlock()
+	}
+
+	s.collection.reset()
+	clear(s.handles)
+	s.readHandles.Clear()
+	clear(s.expiring)
+	clear(s.trash)
+	if s.lru.Load() != nil {
+		s.lru.Store(newLRUTracker())
+	}
+
+	return nil
+}
+
+// ReindexDocument recomputes a single document's membership and key positions
+// across all indexes from its current data. It is a surgical repair for index
+// entries that have drifted from the stored document.
+func (s *Store) ReindexDocument(docID string) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.handles[docID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+
+	if _, exists := s.collection.Get(entry.handle.index); !exists {
+		return ErrDocumentDeleted
+	}
+
+	// Drop whatever the indexes currently believe and re-insert from scratch
+	newIndexes := make([]string, 0, len(s.indexes))
+	for idxName, idx := range s.indexes {
+		idx.purgeDocument(docID)
+		if idx.insertDocument(entry.handle) {
+			newIndexes = append(newIndexes, idxName)
+		}
+	}
+
+	entry.indexes = newIndexes
+	s.handles[docID] = entry
+
+	return nil
+}
+
+// Count returns the number of documents in the store. It runs in constant
+// time, plus a pass over the documents carrying a TTL so that expired ones the
+// sweeper has not removed yet are not counted. A closed store reports zero.
+func (s *Store) Count() int {
+	if s.ensureOpen() != nil {
+		return 0
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.collection.Count() - s.countExpired(time.Now())
+}
+
+// Version returns the store's global version counter, which is the version
+// most recently assigned to a document or to a delete. It advances on every
+// insert, update and delete, so comparing it with an earlier reading tells
+// whether anything has changed since. It can be read at any time, even after
+// Close.
+func (s *Store) Version() uint64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&s.version)
+}
+
+// TrimMemory releases excess slice capacity held by the collection after
+// heavy delete or delete-then-insert churn. Stored documents are unaffected.
+func (s *Store) TrimMemory() {
+	if s.ensureOpen() != nil {
+		return
+	}
+
+	s.collection.TrimFreeSlots()
+}
+
+// Compact moves every document, including soft-deleted ones, into a
+// contiguous block and releases the holes left by deletes. Where TrimMemory
+// only drops free slots at the end of the collection, Compact reclaims all of
+// them, which shrinks memory after bulk deletes. Indexes refer to documents
+// by ID and are left as they are. Documents get new handles, so cursors
+// opened before Compact report their documents as deleted.
+func (s *Store) Compact() error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved := s.collection.compact()
+	for docID, entry := range s.handles {
+		entry.handle = entry.handle.relocate(moved)
+		s.setHandle(docID, entry)
+	}
+	for docID, handle := range s.trash {
+		s.trash[docID] = handle.relocate(moved)
+	}
+
+	return nil
+}
+
+// relocate returns a copy of the handle pointing at the document's new index
+// after a compaction. Existing handles are never modified, as cursors read
+// them without holding the store's lock.
+func (h *DocumentHandle) relocate(moved map[int]int) *DocumentHandle {
+	index, exists := moved[h.index]
+	if !exists {
+		return h
+	}
+
+	return &DocumentHandle{
+		id:        h.id,
+		version:   h.version,
+		index:     index,
+		document:  h.document,
+		expiresAt: h.expiry(),
+	}
+}
+
+// Get retrieves a single document by its ID.
+func (s *Store) Get(docID string) (*DocumentResult, error) {
+	defer s.observe(time.Now(), MetricsHook.OnGet)
+
+	return s.get(docID, true)
+}
+
+// GetRef retrieves a single document like Get but without deep copying its
+// data, which saves the copy's allocations for large documents read on hot
+// paths. The returned Data is shared with the store and with other GetRef
+// callers: it must be treated as read-only, and mutating it corrupts the
+// stored document and its index entries. Writes to the store replace a
+// document's data instead of modifying it, so the result stays a consistent
+// snapshot after later updates. Use Get when the data may be modified.
+func (s *Store) GetRef(docID string) (*DocumentResult, error) {
+	defer s.observe(time.Now(), MetricsHook.OnGet)
+
+	return s.get(docID, false)
+}
+
+// get implements Get and GetRef, deep copying the document's data when
+// copyData is set.
+func (s *Store) get(docID string, copyData bool) (*DocumentResult, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	// Resolve the handle without s.mu so reads don't contend with writers.
+	// The mirror may briefly lag a write in progress, so anything short of a
+	// live document with the expected ID is settled under the lock instead.
+	if value, exists := s.readHandles.Load(docID); exists {
+		handle := value.(*DocumentHandle)
+		if !handle.expired(time.Now()) {
+			if doc, exists := s.collection.get(handle.index, copyData); exists && doc.id == docID {
+				return s.getResult(docID, doc), nil
+			}
+		}
+	}
+
+	return s.getLocked(docID, copyData)
+}
+
+// getLocked resolves a document's handle under s.mu, which get falls back to
+// whenever the lock-free lookup can't settle the read.
+func (s *Store) getLocked(docID string, copyData bool) (*DocumentResult, error) {
+	s.mu.RLock()
+	entry, exists := s.handles[docID]
+	s.mu.RUnlock()
+
+	if !exists || entry.handle.expired(time.Now()) {
+		return nil, ErrDocumentNotFound
+	}
+
+	doc, exists := s.collection.get(entry.handle.index, copyData)
+	if !exists {
+		return nil, ErrDocumentDeleted
+	}
+
+	return s.getResult(docID, doc), nil
+}
+
+// getResult records an access to a document read by get and wraps it as a
+// result.
+func (s *Store) getResult(docID string, doc *Document) *DocumentResult {
+	if lru := s.lru.Load(); lru != nil {
+		lru.touch(docID)
+	}
+
+	return &DocumentResult{
+		ID:        docID,
+		Data:      doc.data,
+		Version:   doc.version,
+		CreatedAt: doc.createdAt,
+		UpdatedAt: doc.updatedAt,
+	}
+}
+
+// GetMany retrieves several documents by ID under a single read lock. IDs that
+// don't exist or have expired are omitted from the result.
+func (s *Store) GetMany(ids []string) (map[string]*DocumentResult, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*DocumentResult, len(ids))
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, docID := range ids {
+		entry, exists := s.handles[docID]
+		if !exists || entry.handle.expired(now) {
+			continue
+		}
+
+		if doc, exists := s.collection.Get(entry.handle.index); exists {
+			if lru := s.lru.Load(); lru != nil {
+				lru.touch(docID)
+			}
+			results[docID] = &DocumentResult{
+				ID:        docID,
+				Data:      doc.data,
+				Version:   doc.version,
+				CreatedAt: doc.createdAt,
+				UpdatedAt: doc.updatedAt,
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// ReadAll returns every live document keyed by ID, taken under a single read
+// lock so it reflects one consistent state of the store. The data is deep
+// copied. It suits moderately sized stores; use Stream for large ones.
+func (s *Store) ReadAll() (map[string]DocumentResult, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make(map[string]DocumentResult, len(s.handles))
+	now := time.Now()
+	for _, doc := range s.collection.GetAllValid() {
+		entry, exists := s.handles[doc.id]
+		if !exists || entry.handle.expired(now) {
+			continue
+		}
+
+		results[doc.id] = DocumentResult{
+			ID:        doc.id,
+			Data:      doc.data,
+			Version:   doc.version,
+			CreatedAt: doc.createdAt,
+			UpdatedAt: doc.updatedAt,
+		}
+	}
+
+	return results, nil
+}
+
+// Stream returns a stream of all documents currently in the store. The
+// documents are deep copies taken when Stream is called, so writes made while
+// the stream is drained are not reflected and the streamed data may be
+// modified freely.
+func (s *Store) Stream(bufferSize int) *DocumentStream {
+	ds := NewDocumentStream(bufferSize)
+
+	if err := s.ensureOpen(); err != nil {
+		s.closeStreamWithError(ds, err)
+		return ds
+	}
+
+	// Get all documents from collection
+	documents := s.liveDocuments()
+
+	// Start streaming
+	go s.streamDocuments(ds, documents)
+	return ds
+}
+
+// StreamOrdered is like Stream but emits documents sorted by sortField, so the
+// order is reproducible across runs. Documents whose sort field is missing or
+// nil come last in either direction, and ties are broken by document ID.
+func (s *Store) StreamOrdered(bufferSize int, sortField string, ascending bool) *DocumentStream {
+	ds := NewDocumentStream(bufferSize)
+
+	if err := s.ensureOpen(); err != nil {
+		s.closeStreamWithError(ds, err)
+		return ds
+	}
+
+	documents := s.liveDocuments()
+	slices.SortFunc(documents, func(a, b *Document) int {
+		aValue, bValue := a.data[sortField], b.data[sortField]
+		switch {
+		case aValue == nil && bValue == nil:
+		case aValue == nil:
+			return 1
+		case bValue == nil:
+			return -1
+		default:
+			cmp := compareValues(aValue, bValue)
+			if !ascending {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp
+			}
+		}
+		return strings.Compare(a.id, b.id)
+	})
+
+	go s.streamDocuments(ds, documents)
+	return ds
+}
+
+// StreamRangeFilter streams the documents within a range of an index that also
+// satisfy pred, narrowing a range scan with conditions that aren't indexable.
+// The predicate receives a copy of each document; a nil predicate matches all.
+func (s *Store) StreamRangeFilter(indexName string, minValues, maxValues []any, pred func(map[string]any) bool, bufferSize int) (*DocumentStream, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	docIDs := index.lookupRange(minValues, maxValues)
+
+	ds := NewDocumentStream(bufferSize)
+	go s.streamDocumentIDs(ds, docIDs, pred)
+	return ds, nil
+}
+
+// StreamLookup streams the documents matching values exactly, like Lookup,
+// but reads each document only as the consumer takes it, so memory stays
+// bounded when one key maps to thousands of documents. Only the matching IDs
+// are collected up front; documents removed before they are reached are
+// skipped. Closing the stream stops it. A missing index is reported before
+// anything is streamed.
+func (s *Store) StreamLookup(indexName string, values []any, bufferSize int) (*DocumentStream, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	docIDs := index.lookup(values)
+
+	ds := NewDocumentStream(bufferSize)
+	go s.streamDocumentIDs(ds, docIDs, nil)
+	return ds, nil
+}
+
+// Clone creates a deep copy of the store with all documents and indexes.
+// The cloned store is completely independent - changes to one store will not affect the other.
+// Returns an error if the store is closed.
+func (s *Store) Clone() (*Store, error) {
+	newStore, _, err := s.CloneCtx(context.Background())
+	return newStore, err
+}
+
+// CloneResult reports how far a clone got. On failure it counts the work done
+// before the error, which was discarded along with the partial clone.
+type CloneResult struct {
+	Documents int // Documents copied
+	Indexes   int // Indexes recreated
+}
+
+// CloneCtx is like Clone but also reports what was copied, and stops early
+// with ctx.Err() once ctx is done, for long clones in backup flows. The source
+// stays read-locked until the clone finishes or stops.
+func (s *Store) CloneCtx(ctx context.Context) (*Store, CloneResult, error) {
+	var result CloneResult
+
+	if err := s.ensureOpen(); err != nil {
+		return nil, result, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, result, err
+	}
+
+	// Lock the source store for reading during cloning
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Create new store instance with the same configuration
+	newStore := NewStore()
+	newStore.keyEqual = s.keyEqual
+	newStore.newID = s.newID
+	newStore.validator = s.validator
+	newStore.maxDocBytes = s.maxDocBytes
+
+	// Set the version counter to match the source. Earlier deletes are not
+	// carried over, so changes before this point can't be streamed
+	atomic.StoreUint64(&newStore.version, atomic.LoadUint64(&s.version))
+	newStore.tombstoneFloor = atomic.LoadUint64(&newStore.version)
+
+	// Clone all valid documents
+	newStore.mu.Lock()
+	documents := s.collection.GetAllValid()
+	for i, doc := range documents {
+		if i%cancellationCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				newStore.mu.Unlock()
+				newStore.Close()
+				return nil, result, err
+			}
+		}
+
+		// Insert document into new store's collection
+		index := newStore.collection.insertAt(doc.id, copyDocument(doc.data), doc.version, doc.createdAt, doc.updatedAt)
+
+		// Create handle for the new store
+		handle := &DocumentHandle{
+			id:    doc.id,
+			index: index,
+		}
+
+		// Create handle entry (indexes will be populated when we recreate indexes)
+		entry := HandleEntry{
+			handle:  handle,
+			indexes: make([]string, 0),
+		}
+
+		newStore.setHandle(doc.id, entry)
+		newStore.copyExpiry(s, handle)
+		result.Documents++
+	}
+	newStore.mu.Unlock()
+
+	// Recreate all indexes with the same configuration
+	for indexName, sourceIndex := range s.indexes {
+		if err := ctx.Err(); err != nil {
+			newStore.Close()
+			return nil, result, err
+		}
+
+		// Create the index (this will automatically populate it with existing documents)
+		err := newStore.addIndex(sourceIndex.emptyCopy(newStore.collection))
+		if err != nil {
+			// This shouldn't happen since we're creating with unique names,
+			// but handle it gracefully
+			newStore.Close()
+			return nil, result, fmt.Errorf("failed to recreate index %s: %w", indexName, err)
+		}
+		result.Indexes++
+	}
+
+	// Carry over the document cap; access history starts fresh in the clone
+	if s.lru.Load() != nil {
+		newStore.SetMaxDocuments(s.maxDocuments, EvictionLRU)
+	}
+
+	return newStore, result, nil
+}
+
+// CloneWithCallback creates a deep copy of the store with an optional callback
+// that gets called for each document during cloning. This allows for selective
+// cloning or document transformation during the clone operation.
+// The callback receives the document and should return true to include it in the clone.
+func (s *Store) CloneWithCallback(callback func(*DocumentResult) bool) (*Store, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Create new store instance with the same configuration
+	newStore := NewStore()
+	newStore.keyEqual = s.keyEqual
+	newStore.newID = s.newID
+	newStore.validator = s.validator
+	newStore.maxDocBytes = s.maxDocBytes
+
+	// Clone documents with callback filtering
+	newStore.mu.Lock()
+	documents := s.collection.GetAllValid()
+	for _, doc := range documents {
+		docResult := &DocumentResult{
+			ID:        doc.id,
+			Data:      copyDocument(doc.data),
+			Version:   doc.version,
+			CreatedAt: doc.createdAt,
+			UpdatedAt: doc.updatedAt,
+		}
+
+		// Apply callback filter
+		if callback != nil && !callback(docResult) {
+			continue // Skip this document
+		}
+
+		// Insert document into new store's collection
+		index := newStore.collection.insertAt(doc.id, docResult.Data, doc.version, doc.createdAt, doc.updatedAt)
+
+		// Create handle for the new store
+		handle := &DocumentHandle{
+			id:    doc.id,
+			index: index,
+		}
+
+		// Create handle entry
+		entry := HandleEntry{
+			handle:  handle,
+			indexes: make([]string, 0),
+		}
+
+		newStore.setHandle(doc.id, entry)
+		newStore.copyExpiry(s, handle)
+	}
+	newStore.mu.Unlock()
+
+	// Recreate all indexes with the same configuration
+	for indexName, sourceIndex := range s.indexes {
+		err := newStore.addIndex(sourceIndex.emptyCopy(newStore.collection))
+		if err != nil {
+			newStore.Close()
+			return nil, fmt.Errorf("failed to recreate index %s: %w", indexName, err)
+		}
+	}
+
+	// Set version counter based on what was actually cloned
+	if len(newStore.handles) > 0 {
+		atomic.StoreUint64(&newStore.version, atomic.LoadUint64(&s.version))
+		newStore.tombstoneFloor = atomic.LoadUint64(&newStore.version)
+	}
+
+	// Carry over the document cap; access history starts fresh in the clone
+	if s.lru.Load() != nil {
+		newStore.SetMaxDocuments(s.maxDocuments, EvictionLRU)
+	}
+
+	return newStore, nil
+}
+
+// streamDocuments runs the actual streaming logic in a goroutine.
+func (s *Store) streamDocuments(ds *DocumentStream, documents []*Document) {
+	defer close(ds.results)
+	defer close(ds.errors)
+
+	// Block until the consumer has room rather than dropping documents when
+	// the buffer is full; only closing the stream ends it early.
+	for _, doc := range documents {
+		result := DocumentResult{
+			ID:        doc.id,
+			Data:      doc.data,
+			Version:   doc.version,
+			CreatedAt: doc.createdAt,
+			UpdatedAt: doc.updatedAt,
+		}
+
+		select {
+		case ds.results <- result:
+		case <-ds.ctx.Done():
+			return
+		}
+	}
+}
 
-	// Create handle
-	handle := &DocumentHandle{
-		id:    docID,
-		index: index,
+// streamDocumentIDs resolves and streams documents by ID, skipping any that
+// have since been removed or expired or that fail the optional predicate.
+func (s *Store) streamDocumentIDs(ds *DocumentStream, docIDs []string, pred func(map[string]any) bool) {
+	defer close(ds.results)
+	defer close(ds.errors)
+
+	for _, docID := range docIDs {
+		if ds.ctx.Err() != nil {
+			return
+		}
+
+		s.mu.RLock()
+		handle, live := s.liveHandle(docID, time.Now())
+		s.mu.RUnlock()
+		if !live {
+			continue
+		}
+
+		doc, exists := s.collection.Get(handle.index)
+		if !exists || (pred != nil && !pred(doc.data)) {
+			continue
+		}
+
+		result := DocumentResult{
+			ID:        docID,
+			Data:      doc.data,
+			Version:   doc.version,
+			CreatedAt: doc.createdAt,
+			UpdatedAt: doc.updatedAt,
+		}
+
+		select {
+		case ds.results <- result:
+		case <-ds.ctx.Done():
+			return
+		}
 	}
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// closeStreamWithError closes a stream with an error.
+func (s *Store) closeStreamWithError(ds *DocumentStream, err error) {
+	go func() {
+		defer close(ds.results)
+		defer close(ds.errors)
+		ds.errors <- err
+	}()
+}
 
-	// Create handle entry
-	entry := HandleEntry{
-		handle:  handle,
-		indexes: make([]string, 0, len(s.indexes)),
+// CreateIndex builds a new index on the specified fields. Documents whose
+// indexed fields are missing, nil, slices or maps are not indexed.
+func (s *Store) CreateIndex(indexName string, fields []string) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
 	}
 
-	// Update all indexes synchronously
-	for idxName, idx := range s.indexes {
-		if idx.insertDocument(handle) {
-			entry.indexes = append(entry.indexes, idxName)
-		}
+	if len(fields) == 0 {
+		return ErrEmptyIndex
 	}
 
-	// Add handle entry to store
-	s.handles[docID] = entry
+	return s.addIndex(newFieldIndex(indexName, fields, s.collection))
+}
 
-	return docID, nil
+// CreateIndexWithDegree builds an index like CreateIndex but with a B-tree of
+// the given degree instead of the default 32. Each node holds up to
+// 2*degree-1 keys, so larger degrees make the tree shallower at the cost of
+// moving more keys per node split. The degree must be at least 2; values
+// between 8 and 128 suit most workloads, and BenchmarkIndexDegree measures
+// the trade-off.
+func (s *Store) CreateIndexWithDegree(indexName string, fields []string, degree int) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	if len(fields) == 0 {
+		return ErrEmptyIndex
+	}
+
+	if degree < 2 {
+		return ErrInvalidDegree
+	}
+
+	return s.addIndex(newFieldIndexWithDegree(indexName, fields, s.collection, degree))
 }
 
-// Update modifies an existing document and updates all affected indexes.
-func (s *Store) Update(docID string, doc map[string]any) error {
-	if s.closed.Load() {
-		return ErrStoreClosed
+// CreateIndexWithComparator builds an index like CreateIndex whose B-tree
+// orders keys with cmp instead of the default ordering, for example to sort
+// string-encoded numbers numerically or strings by locale. cmp returns a
+// negative number, zero or a positive number as a sorts before, equal to or
+// after b, and must be a strict weak ordering that is consistent every time
+// it is called, or lookups will miss documents. Keys that cmp reports equal
+// share an index entry. It is also called with lookup bounds, which may be
+// shorter than the index's keys, and with nil bound components, which it must
+// tolerate. Range lookups, prefix lookups and Join all follow cmp; NullsLast
+// has no effect, as cmp alone decides where nil sorts. A nil cmp is rejected
+// with ErrNilComparator.
+func (s *Store) CreateIndexWithComparator(indexName string, fields []string, cmp func(a, b []any) int) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
 	}
 
-	if doc == nil {
-		return ErrInvalidDocument
+	if len(fields) == 0 {
+		return ErrEmptyIndex
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	if cmp == nil {
+		return ErrNilComparator
+	}
 
-	entry, exists := s.handles[docID]
-	if !exists {
-		return ErrDocumentNotFound
+	index := newFieldIndex(indexName, fields, s.collection)
+	index.cmp = cmp
+	return s.addIndex(index)
+}
+
+// CreatePartialIndex builds an index on the specified fields that only
+// contains documents for which filter returns true. The filter is re-evaluated
+// on every insert and update, so documents enter and leave the index as their
+// data changes. The filter receives a copy of the document.
+func (s *Store) CreatePartialIndex(indexName string, fields []string, filter func(map[string]any) bool) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
 	}
 
-	// Get old data for index updates
-	currentDoc, exists := s.collection.Get(entry.handle.index)
-	if !exists {
-		return ErrDocumentDeleted
+	if len(fields) == 0 {
+		return ErrEmptyIndex
 	}
 
-	currentData := copyDocument(currentDoc.data)
+	index := newFieldIndex(indexName, fields, s.collection)
+	index.filter = filter
+	return s.addIndex(index)
+}
 
-	// Update in collection
-	version := atomic.AddUint64(&s.version, 1)
-	if !s.collection.Update(entry.handle.index, doc, version) {
-		return ErrDocumentDeleted
+// CreateIndexWithTransform builds an index on the specified fields whose key
+// values are passed through transform before being stored. Query values given
+// to the lookup methods are transformed the same way, so for example
+// LowerCaseTransform yields case-insensitive string matching. The transform
+// must be deterministic: a value has to map to the same result every time, or
+// documents become unreachable through the index.
+func (s *Store) CreateIndexWithTransform(indexName string, fields []string, transform func(any) any) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
 	}
 
-	// Update indexes and track new membership
-	newIndexes := make([]string, 0, len(s.indexes))
-	for idxName, idx := range s.indexes {
-		if idx.updateDocument(entry.handle, currentData) {
-			newIndexes = append(newIndexes, idxName)
+	if len(fields) == 0 {
+		return ErrEmptyIndex
+	}
+
+	index := newFieldIndex(indexName, fields, s.collection)
+	index.transform = transform
+	return s.addIndex(index)
+}
+
+// CreateFunctionalIndex builds an index keyed by a value derived from each
+// document rather than by stored fields, such as price * quantity or the year
+// of a timestamp. fn is called on every insert and update and returns the key
+// and true, or false to leave the document out of the index. Query the index
+// with single-value keys, e.g. Lookup(indexName, []any{key}). fn receives a
+// copy of the document and must be deterministic.
+func (s *Store) CreateFunctionalIndex(indexName string, fn func(map[string]any) (any, bool)) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	if fn == nil {
+		return ErrEmptyIndex
+	}
+
+	index := newFieldIndex(indexName, nil, s.collection)
+	index.keyFunc = fn
+	return s.addIndex(index)
+}
+
+// CreateTypedIndex builds an index like CreateIndex whose fields only accept
+// values of the declared kinds, one per field. Documents with a value of a
+// different kind, such as the string "5" where reflect.Int is declared, are
+// left out of the index rather than being ordered among the other keys by
+// type name. Note that numbers decoded from JSON are float64. Use
+// CreateStrictTypedIndex to reject such documents instead.
+func (s *Store) CreateTypedIndex(indexName string, fields []string, kinds []reflect.Kind) error {
+	return s.createTypedIndex(indexName, fields, kinds, false, defaultIndexDegree)
+}
+
+// CreateStrictTypedIndex builds a typed index like CreateTypedIndex, but
+// writes whose indexed values have the wrong kind fail with ErrTypeMismatch
+// and leave the store unchanged. Creating the index fails the same way if an
+// existing document doesn't match.
+func (s *Store) CreateStrictTypedIndex(indexName string, fields []string, kinds []reflect.Kind) error {
+	return s.createTypedIndex(indexName, fields, kinds, true, defaultIndexDegree)
+}
+
+// createTypedIndex validates a typed index definition and adds the index,
+// backed by a B-tree of the given degree.
+func (s *Store) createTypedIndex(indexName string, fields []string, kinds []reflect.Kind, strict bool, degree int) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	if len(fields) == 0 {
+		return ErrEmptyIndex
+	}
+
+	if len(kinds) != len(fields) {
+		return ErrInvalidKinds
+	}
+
+	if degree < 2 {
+		return ErrInvalidDegree
+	}
+
+	index := newFieldIndexWithDegree(indexName, fields, s.collection, degree)
+	index.kinds = slices.Clone(kinds)
+	index.strict = strict
+	return s.addIndex(index)
+}
+
+// CreateArrayIndex builds a multi-key index on a single field: when the field
+// holds a slice, the document is filed under each distinct element, so
+// Lookup(indexName, []any{"a"}) finds every document whose tags contain "a".
+// A scalar value is indexed as a one-element slice. Elements that are nil,
+// slices or maps are skipped. Range lookups and CountRange report a document
+// once even if several elements match, but ReadIndex and Join visit it once
+// per element.
+func (s *Store) CreateArrayIndex(indexName string, field string) error {
+	return s.createArrayIndex(indexName, field, defaultIndexDegree)
+}
+
+// createArrayIndex adds an array index backed by a B-tree of the given degree.
+func (s *Store) createArrayIndex(indexName string, field string, degree int) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	if field == "" {
+		return ErrEmptyIndex
+	}
+
+	if degree < 2 {
+		return ErrInvalidDegree
+	}
+
+	index := newFieldIndexWithDegree(indexName, []string{field}, s.collection, degree)
+	index.explode = arrayElements
+	index.array = true
+	return s.addIndex(index)
+}
+
+// CreateTextIndex builds a multi-key index that files each document under the
+// tokens tokenize splits its string field into, for word search with Search.
+// A nil tokenize uses DefaultTokenizer. Non-string values are not indexed. The
+// tokenizer must be deterministic, and is re-run on every insert, update and
+// delete to keep the index consistent.
+func (s *Store) CreateTextIndex(indexName string, field string, tokenize func(string) []string) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	if field == "" {
+		return ErrEmptyIndex
+	}
+
+	if tokenize == nil {
+		tokenize = DefaultTokenizer
+	}
+
+	index := newFieldIndex(indexName, []string{field}, s.collection)
+	index.tokenize = tokenize
+	index.explode = func(value any) []any {
+		text, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		tokens := tokenize(text)
+		elements := make([]any, len(tokens))
+		for i, token := range tokens {
+			elements[i] = token
 		}
+		return elements
 	}
+	return s.addIndex(index)
+}
 
-	// Update handle entry with new index membership
-	entry.indexes = newIndexes
-	s.handles[docID] = entry
+// DefaultTokenizer splits text into lowercase words at whitespace.
+func DefaultTokenizer(text string) []string {
+	return strings.Fields(strings.ToLower(text))
+}
 
-	return nil
+// LowerCaseTransform lowercases string values and leaves all other values
+// unchanged. Use it with CreateIndexWithTransform for case-insensitive indexes.
+func LowerCaseTransform(value any) any {
+	if str, ok := value.(string); ok {
+		return strings.ToLower(str)
+	}
+	return value
 }
 
-// Delete removes a document from the store and all indexes.
-func (s *Store) Delete(docID string) error {
-	if s.closed.Load() {
-		return ErrStoreClosed
+// addIndex registers a new index and populates it with existing documents.
+func (s *Store) addIndex(index *fieldIndex) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	entry, exists := s.handles[docID]
-	if !exists {
-		return ErrDocumentNotFound
+	if _, exists := s.indexes[index.name]; exists {
+		return ErrIndexExists
 	}
 
-	// Get document data for index cleanup
-	doc, exists := s.collection.Get(entry.handle.index)
-	if !exists {
-		return ErrDocumentDeleted
+	if index.strict {
+		for _, entry := range s.handles {
+			doc, exists := s.collection.Get(entry.handle.index)
+			if !exists {
+				continue
+			}
+			if err := index.typeError(doc.data); err != nil {
+				return err
+			}
+		}
 	}
-	docData := copyDocument(doc.data)
 
-	// Remove from only the indexes this document is actually in
-	for _, indexName := range entry.indexes {
-		if idx, exists := s.indexes[indexName]; exists {
-			idx.deleteDocument(docID, docData)
+	index.hook = &s.indexHook
+	s.indexes[index.name] = index
+
+	// Populate with existing documents and update handle entries
+	for docID, entry := range s.handles {
+		if index.insertDocument(entry.handle) {
+			// Update handle entry to include new index
+			entry.indexes = append(entry.indexes, index.name)
+			s.handles[docID] = entry
 		}
 	}
 
-	// Remove from collection and handles
-	s.collection.Delete(entry.handle.index)
-	delete(s.handles, docID)
+	return nil
+}
+
+// DropIndex removes an existing index from the store.
+func (s *Store) DropIndex(indexName string) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.indexes[indexName]; !exists {
+		return ErrIndexNotFound
+	}
+
+	// Remove index from all handle entries
+	for docID, entry := range s.handles {
+		newIndexes := make([]string, 0, len(entry.indexes))
+		for _, idxName := range entry.indexes {
+			if idxName != indexName {
+				newIndexes = append(newIndexes, idxName)
+			}
+		}
+		entry.indexes = newIndexes
+		s.handles[docID] = entry
+	}
 
+	delete(s.indexes, indexName)
 	return nil
 }
 
-// Get retrieves a single document by its ID.
-func (s *Store) Get(docID string) (*DocumentResult, error) {
-	if s.closed.Load() {
-		return nil, ErrStoreClosed
+// HasIndex reports whether an index with the given name exists. A closed
+// store has no indexes.
+func (s *Store) HasIndex(indexName string) bool {
+	if s.ensureOpen() != nil {
+		return false
 	}
 
 	s.mu.RLock()
-	entry, exists := s.handles[docID]
-	s.mu.RUnlock()
+	defer s.mu.RUnlock()
 
-	if !exists {
-		return nil, ErrDocumentNotFound
+	_, exists := s.indexes[indexName]
+	return exists
+}
+
+// RebuildIndex discards the contents of an index and rebuilds it from the
+// current documents, resyncing each document's index membership. It repairs
+// an index that has drifted out of sync without dropping its definition.
+func (s *Store) RebuildIndex(indexName string) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
 	}
 
-	doc, exists := s.collection.Get(entry.handle.index)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.indexes[indexName]
 	if !exists {
-		return nil, ErrDocumentDeleted
+		return ErrIndexNotFound
 	}
 
-	return &DocumentResult{
-		ID:      docID,
-		Data:    doc.data,
-		Version: doc.version,
-	}, nil
+	index.mu.Lock()
+	index.tree.Clear(false)
+	index.mu.Unlock()
+
+	for docID, entry := range s.handles {
+		entry.indexes = slices.DeleteFunc(entry.indexes, func(name string) bool {
+			return name == indexName
+		})
+		if index.insertDocument(entry.handle) {
+			entry.indexes = append(entry.indexes, indexName)
+		}
+		s.handles[docID] = entry
+	}
+
+	return nil
+}
+
+// IndexInfo describes an index defined on a store.
+type IndexInfo struct {
+	Name   string
+	Fields []string
+}
+
+// ListIndexes returns the indexes defined on the store, sorted by name.
+// The result is a copy and may be modified freely.
+func (s *Store) ListIndexes() []IndexInfo {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]IndexInfo, 0, len(s.indexes))
+	for name, index := range s.indexes {
+		infos = append(infos, IndexInfo{
+			Name:   name,
+			Fields: slices.Clone(index.fields),
+		})
+	}
+
+	slices.SortFunc(infos, func(a, b IndexInfo) int { return strings.Compare(a.Name, b.Name) })
+	return infos
+}
+
+// IndexStats reports statistics for the named index: the number of distinct
+// keys ("entries"), indexed documents ("total_docs"), the indexed "fields",
+// and the average and maximum documents per key, which reveal skew.
+func (s *Store) IndexStats(indexName string) (map[string]any, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	return index.stats(), nil
+}
+
+// Lookup finds documents using an exact match on an index.
+func (s *Store) Lookup(indexName string, values []any) ([]*DocumentResult, error) {
+	return s.LookupCtx(context.Background(), indexName, values)
+}
+
+// LookupOne finds the single document matching values exactly, as expected of
+// a lookup by unique key. It returns ErrDocumentNotFound if nothing matches
+// and ErrMultipleMatches if more than one document does.
+func (s *Store) LookupOne(indexName string, values []any) (*DocumentResult, error) {
+	results, err := s.Lookup(indexName, values)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(results) {
+	case 0:
+		return nil, ErrDocumentNotFound
+	case 1:
+		return results[0], nil
+	default:
+		return nil, fmt.Errorf("%w: %d documents", ErrMultipleMatches, len(results))
+	}
 }
 
-// Stream returns a stream of all documents currently in the store.
-func (s *Store) Stream(bufferSize int) *DocumentStream {
-	ds := NewDocumentStream(bufferSize)
+// LookupCtx is like Lookup but stops early with ctx.Err() once ctx is done.
+func (s *Store) LookupCtx(ctx context.Context, indexName string, values []any) (results []*DocumentResult, err error) {
+	defer s.observeLookup(time.Now(), &results)
 
-	if s.closed.Load() {
-		s.closeStreamWithError(ds, ErrStoreClosed)
-		return ds
+	index, err := s.indexForQuery(ctx, indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get all documents from collection
-	documents := s.collection.GetAllValid()
+	return s.lookupWithIndex(ctx, index, values)
+}
 
-	// Start streaming
-	go s.streamDocuments(ds, documents)
-	return ds
+// LookupIDs is like Lookup but returns only the matching document IDs, in
+// ascending order, straight from the index. No documents are read or copied,
+// which makes it much cheaper for existence checks and set operations; only
+// documents carrying a TTL are checked so that expired ones are left out.
+func (s *Store) LookupIDs(indexName string, values []any) ([]string, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.liveIDs(index.lookup(values)), nil
 }
 
-// Clone creates a deep copy of the store with all documents and indexes.
-// The cloned store is completely independent - changes to one store will not affect the other.
-// Returns an error if the store is closed.
-func (s *Store) Clone() (*Store, error) {
-	if s.closed.Load() {
-		return nil, ErrStoreClosed
+// LookupAny finds documents exactly matching any of the given key tuples, like
+// SQL's IN (...). Each document appears once even if it matches several keys.
+func (s *Store) LookupAny(indexName string, valueSets [][]any) (results []*DocumentResult, err error) {
+	defer s.observeLookup(time.Now(), &results)
+
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Lock the source store for reading during cloning
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	// Deduplicate IDs before materializing so each document is copied once
+	seen := make(map[string]struct{})
+	var docIDs []string
+	for _, values := range valueSets {
+		for _, docID := range index.lookup(values) {
+			if _, exists := seen[docID]; !exists {
+				seen[docID] = struct{}{}
+				docIDs = append(docIDs, docID)
+			}
+		}
+	}
 
-	// Create new store instance
-	newStore := NewStore()
+	return s.collectDocumentResults(context.Background(), docIDs)
+}
 
-	// Set the version counter to match the source
-	atomic.StoreUint64(&newStore.version, atomic.LoadUint64(&s.version))
+// IndexQuery describes a single index condition for LookupIntersect. When
+// Values is non-nil it is an exact match; otherwise the range [Min, Max) is used.
+type IndexQuery struct {
+	Index  string
+	Values []any
+	Min    []any
+	Max    []any
+}
 
-	// Clone all valid documents
-	documents := s.collection.GetAllValid()
-	for _, doc := range documents {
-		// Insert document into new store's collection
-		index := newStore.collection.Insert(doc.id, copyDocument(doc.data), doc.version)
+// LookupIntersect finds documents that satisfy every query, combining
+// selective indexes instead of scanning, and returns them sorted by ID like
+// Lookup. An empty spec list matches nothing.
+func (s *Store) LookupIntersect(specs []IndexQuery) (results []*DocumentResult, err error) {
+	defer s.observeLookup(time.Now(), &results)
 
-		// Create handle for the new store
-		handle := &DocumentHandle{
-			id:    doc.id,
-			index: index,
+	candidates := make([][]string, 0, len(specs))
+	for _, spec := range specs {
+		index, err := s.indexForQuery(context.Background(), spec.Index)
+		if err != nil {
+			return nil, err
 		}
 
-		// Create handle entry (indexes will be populated when we recreate indexes)
-		entry := HandleEntry{
-			handle:  handle,
-			indexes: make([]string, 0),
+		if spec.Values != nil {
+			candidates = append(candidates, index.lookup(spec.Values))
+		} else {
+			candidates = append(candidates, index.lookupRange(spec.Min, spec.Max))
 		}
+	}
 
-		newStore.handles[doc.id] = entry
+	if len(candidates) == 0 {
+		return []*DocumentResult{}, nil
 	}
 
-	// Recreate all indexes with the same configuration
-	for indexName, sourceIndex := range s.indexes {
-		// Create the index (this will automatically populate it with existing documents)
-		err := newStore.CreateIndex(indexName, sourceIndex.fields)
-		if err != nil {
-			// This shouldn't happen since we're creating with unique names,
-			// but handle it gracefully
-			return nil, fmt.Errorf("failed to recreate index %s: %w", indexName, err)
+	// Start from the smallest candidate list so the working set stays small
+	slices.SortFunc(candidates, func(a, b []string) int { return len(a) - len(b) })
+
+	matched := make(map[string]struct{}, len(candidates[0]))
+	for _, docID := range candidates[0] {
+		matched[docID] = struct{}{}
+	}
+
+	for _, docIDs := range candidates[1:] {
+		if len(matched) == 0 {
+			break
+		}
+		next := make(map[string]struct{}, len(matched))
+		for _, docID := range docIDs {
+			if _, exists := matched[docID]; exists {
+				next[docID] = struct{}{}
+			}
 		}
+		matched = next
 	}
 
-	return newStore, nil
+	docIDs := make([]string, 0, len(matched))
+	for docID := range matched {
+		docIDs = append(docIDs, docID)
+	}
+	slices.Sort(docIDs)
+
+	return s.collectDocumentResults(context.Background(), docIDs)
 }
 
-// CloneWithCallback creates a deep copy of the store with an optional callback
-// that gets called for each document during cloning. This allows for selective
-// cloning or document transformation during the clone operation.
-// The callback receives the document and should return true to include it in the clone.
-func (s *Store) CloneWithCallback(callback func(*DocumentResult) bool) (*Store, error) {
-	if s.closed.Load() {
-		return nil, ErrStoreClosed
+// Search finds the documents in a text index that contain term. The term is
+// split by the index's tokenizer, so with DefaultTokenizer it matches
+// regardless of case, and a term of several words matches documents holding
+// all of them. On other indexes the term is looked up as a single key.
+func (s *Store) Search(indexName string, term string) (results []*DocumentResult, err error) {
+	defer s.observeLookup(time.Now(), &results)
+
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	tokens := []string{term}
+	if index.tokenize != nil {
+		tokens = index.tokenize(term)
+	}
+	if len(tokens) == 0 {
+		return []*DocumentResult{}, nil
+	}
 
-	// Create new store instance
-	newStore := NewStore()
+	// lookup returns IDs in ascending order, so they can be binary searched
+	docIDs := index.lookup([]any{tokens[0]})
+	for _, token := range tokens[1:] {
+		matched := index.lookup([]any{token})
+		docIDs = slices.DeleteFunc(docIDs, func(docID string) bool {
+			_, found := slices.BinarySearch(matched, docID)
+			return !found
+		})
+	}
 
-	// Clone documents with callback filtering
-	documents := s.collection.GetAllValid()
-	for _, doc := range documents {
-		docResult := &DocumentResult{
-			ID:      doc.id,
-			Data:    copyDocument(doc.data),
-			Version: doc.version,
-		}
+	return s.collectDocumentResults(context.Background(), docIDs)
+}
 
-		// Apply callback filter
-		if callback != nil && !callback(docResult) {
-			continue // Skip this document
-		}
+// LookupPrefix finds documents whose composite key starts with prefixValues,
+// for example every document with city "NYC" on a (city, age) index. An empty
+// prefix returns every document in the index.
+func (s *Store) LookupPrefix(indexName string, prefixValues []any) (results []*DocumentResult, err error) {
+	defer s.observeLookup(time.Now(), &results)
 
-		// Insert document into new store's collection
-		index := newStore.collection.Insert(doc.id, docResult.Data, doc.version)
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
+	}
 
-		// Create handle for the new store
-		handle := &DocumentHandle{
-			id:    doc.id,
-			index: index,
-		}
+	return s.collectDocumentResults(context.Background(), index.lookupPrefix(prefixValues))
+}
 
-		// Create handle entry
-		entry := HandleEntry{
-			handle:  handle,
-			indexes: make([]string, 0),
-		}
+// LookupRange finds documents within a range using an index.
+func (s *Store) LookupRange(indexName string, minValues, maxValues []any) ([]*DocumentResult, error) {
+	return s.LookupRangeCtx(context.Background(), indexName, minValues, maxValues)
+}
+
+// LookupRangeCtx is like LookupRange but stops early with ctx.Err() once ctx is done.
+func (s *Store) LookupRangeCtx(ctx context.Context, indexName string, minValues, maxValues []any) (results []*DocumentResult, err error) {
+	defer s.observeLookup(time.Now(), &results)
 
-		newStore.handles[doc.id] = entry
+	index, err := s.indexForQuery(ctx, indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Recreate all indexes with the same configuration
-	for indexName, sourceIndex := range s.indexes {
-		err := newStore.CreateIndex(indexName, sourceIndex.fields)
-		if err != nil {
-			return nil, fmt.Errorf("failed to recreate index %s: %w", indexName, err)
-		}
+	return s.lookupRangeWithIndex(ctx, index, minValues, maxValues)
+}
+
+// LookupRangeIDs is like LookupRange but returns only the matching document
+// IDs, in key order, straight from the index without reading any documents.
+// Expired documents are left out, as LookupRange leaves them out.
+func (s *Store) LookupRangeIDs(indexName string, minValues, maxValues []any) ([]string, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Set version counter based on what was actually cloned
-	if len(newStore.handles) > 0 {
-		atomic.StoreUint64(&newStore.version, atomic.LoadUint64(&s.version))
+	return s.liveIDs(index.lookupRange(minValues, maxValues)), nil
+}
+
+// CountRange returns how many documents LookupRange would match without
+// reading any documents. While no document carries a TTL the count is summed
+// straight from the index entries; otherwise the matching IDs are checked so
+// that expired documents are left out, as LookupRange leaves them out.
+func (s *Store) CountRange(indexName string, minValues, maxValues []any) (int, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return 0, err
 	}
 
-	return newStore, nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.countLive(index, minValues, maxValues), nil
 }
 
-// streamDocuments runs the actual streaming logic in a goroutine.
-func (s *Store) streamDocuments(ds *DocumentStream, documents []*Document) {
-	defer close(ds.results)
-	defer close(ds.errors)
+// LookupRangeOpen finds documents in [minValues, maxValues) like LookupRange,
+// but a nil or empty bound leaves that side of the range open: a nil
+// maxValues means every key from minValues upwards, a nil minValues every key
+// below maxValues, and both nil the whole index. LookupRange has no such
+// special case, so an empty bound there compares as a key shorter than every
+// other.
+func (s *Store) LookupRangeOpen(indexName string, minValues, maxValues []any) (results []*DocumentResult, err error) {
+	defer s.observeLookup(time.Now(), &results)
 
-	for _, doc := range documents {
-		select {
-		case <-ds.ctx.Done():
-			return
-		default:
-			result := DocumentResult{
-				ID:      doc.id,
-				Data:    doc.data,
-				Version: doc.version,
-			}
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
+	}
 
-			select {
-			case ds.results <- result:
-			case <-ds.ctx.Done():
-				return
-			}
-		}
+	return s.collectDocumentResults(context.Background(), index.lookupOpen(minValues, maxValues))
+}
+
+// LookupRangeBounds finds documents between minValues and maxValues with
+// explicit control over whether each bound is included. LookupRange is
+// equivalent to minInclusive true and maxInclusive false.
+func (s *Store) LookupRangeBounds(indexName string, minValues, maxValues []any, minInclusive, maxInclusive bool) (results []*DocumentResult, err error) {
+	defer s.observeLookup(time.Now(), &results)
+
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
+
+	docIDs := index.lookupBounds(minValues, maxValues, minInclusive, maxInclusive)
+	return s.collectDocumentResults(context.Background(), docIDs)
 }
 
-// closeStreamWithError closes a stream with an error.
-func (s *Store) closeStreamWithError(ds *DocumentStream, err error) {
-	go func() {
-		defer close(ds.results)
-		defer close(ds.errors)
-		ds.errors <- err
-	}()
+// RangeOptions adjusts how LookupRangeOpts interprets its bounds.
+type RangeOptions struct {
+	// NullsLast orders nil bound components after every value instead of
+	// before. Documents with missing or nil indexed fields are never indexed,
+	// so this only changes what nil means inside the bounds: by default a nil
+	// component in maxValues matches nothing beyond the preceding components,
+	// while with NullsLast it leaves that position unbounded above. For
+	// example, on an index over [city, age], min {"NYC"} and max {"NYC", nil}
+	// select every NYC document only when NullsLast is set.
+	NullsLast bool
 }
 
-// CreateIndex builds a new index on the specified fields.
-func (s *Store) CreateIndex(indexName string, fields []string) error {
-	if s.closed.Load() {
-		return ErrStoreClosed
-	}
+// LookupRangeOpts finds documents within a range of values like LookupRange,
+// with options controlling how the bounds are interpreted.
+func (s *Store) LookupRangeOpts(indexName string, minValues, maxValues []any, opts RangeOptions) (results []*DocumentResult, err error) {
+	defer s.observeLookup(time.Now(), &results)
 
-	if len(fields) == 0 {
-		return ErrEmptyIndex
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if _, exists := s.indexes[indexName]; exists {
-		return ErrIndexExists
+	var docIDs []string
+	if opts.NullsLast {
+		docIDs = index.lookupRangeNullsLast(minValues, maxValues)
+	} else {
+		docIDs = index.lookupRange(minValues, maxValues)
 	}
+	return s.collectDocumentResults(context.Background(), docIDs)
+}
 
-	// Create new index
-	index := newFieldIndex(indexName, fields, s.collection)
-	s.indexes[indexName] = index
+// IndexMin returns a document holding the smallest key in the named index,
+// found in O(log n) without a range scan; keys whose documents have all
+// expired are passed over. When several documents share that key, the one
+// with the lowest ID is returned. An empty index reports ErrDocumentNotFound.
+func (s *Store) IndexMin(indexName string) (*DocumentResult, error) {
+	return s.indexExtreme(indexName, false)
+}
 
-	// Populate with existing documents and update handle entries
-	for docID, entry := range s.handles {
-		if index.insertDocument(entry.handle) {
-			// Update handle entry to include new index
-			entry.indexes = append(entry.indexes, indexName)
-			s.handles[docID] = entry
-		}
+// IndexMax returns a document holding the largest key in the named index,
+// like IndexMin.
+func (s *Store) IndexMax(indexName string) (*DocumentResult, error) {
+	return s.indexExtreme(indexName, true)
+}
+
+// DistinctValues returns each distinct key tuple present in the named index,
+// in sorted key order, without materializing any documents. For transform
+// indexes the keys are the transformed values.
+func (s *Store) DistinctValues(indexName string) ([][]any, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return index.distinctKeys(s.liveFilter()), nil
 }
 
-// DropIndex removes an existing index from the store.
-func (s *Store) DropIndex(indexName string) error {
-	if s.closed.Load() {
-		return ErrStoreClosed
+// LookupCovered answers an exact lookup from the index alone, returning the
+// matching key tuple once per matching document without fetching any
+// documents. It suits queries that only need the indexed fields, such as
+// counting matches per key. For transform indexes the keys are the
+// transformed values.
+func (s *Store) LookupCovered(indexName string, values []any) ([][]any, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	if _, exists := s.indexes[indexName]; !exists {
-		return ErrIndexNotFound
-	}
+	return index.coveredLookup(values, s.liveFilter()), nil
+}
 
-	// Remove index from all handle entries
-	for docID, entry := range s.handles {
-		newIndexes := make([]string, 0, len(entry.indexes))
-		for _, idxName := range entry.indexes {
-			if idxName != indexName {
-				newIndexes = append(newIndexes, idxName)
-			}
-		}
-		entry.indexes = newIndexes
-		s.handles[docID] = entry
+// LookupRangeCovered is the range form of LookupCovered: it returns the key of
+// every document whose key lies in [minValues, maxValues), in key order.
+func (s *Store) LookupRangeCovered(indexName string, minValues, maxValues []any) ([][]any, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
 
-	delete(s.indexes, indexName)
-	return nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return index.coveredRange(minValues, maxValues, s.liveFilter()), nil
 }
 
-// Lookup finds documents using an exact match on an index.
-func (s *Store) Lookup(indexName string, values []any) ([]*DocumentResult, error) {
-	if s.closed.Load() {
-		return nil, ErrStoreClosed
+// indexExtreme resolves the first document under the smallest or largest key
+// that still holds a document which has not expired.
+func (s *Store) indexExtreme(indexName string, largest bool) (*DocumentResult, error) {
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return nil, err
 	}
 
 	s.mu.RLock()
-	index, exists := s.indexes[indexName]
+	docIDs := index.extreme(largest, s.liveFilter())
 	s.mu.RUnlock()
 
-	if !exists {
-		return nil, ErrIndexNotFound
+	results, err := s.collectDocumentResults(context.Background(), docIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrDocumentNotFound
 	}
 
-	return s.lookupWithIndex(index, values)
+	return results[0], nil
 }
 
-// LookupRange finds documents within a range using an index.
-func (s *Store) LookupRange(indexName string, minValues, maxValues []any) ([]*DocumentResult, error) {
-	if s.closed.Load() {
-		return nil, ErrStoreClosed
+// indexForQuery resolves an index by name for a read-only query.
+func (s *Store) indexForQuery(ctx context.Context, indexName string) (*fieldIndex, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	s.mu.RLock()
@@ -879,53 +3555,105 @@ func (s *Store) LookupRange(indexName string, minValues, maxValues []any) ([]*Do
 		return nil, ErrIndexNotFound
 	}
 
-	return s.lookupRangeWithIndex(index, minValues, maxValues)
+	return index, nil
 }
 
 // lookupWithIndex performs an exact lookup using the specified index.
-func (s *Store) lookupWithIndex(index *fieldIndex, values []any) ([]*DocumentResult, error) {
+func (s *Store) lookupWithIndex(ctx context.Context, index *fieldIndex, values []any) ([]*DocumentResult, error) {
 	docIDs := index.lookup(values)
-	return s.collectDocumentResults(docIDs), nil
+	return s.collectDocumentResults(ctx, docIDs)
 }
 
 // lookupRangeWithIndex performs a range lookup using the specified index.
-func (s *Store) lookupRangeWithIndex(index *fieldIndex, minValues, maxValues []any) ([]*DocumentResult, error) {
+func (s *Store) lookupRangeWithIndex(ctx context.Context, index *fieldIndex, minValues, maxValues []any) ([]*DocumentResult, error) {
 	docIDs := index.lookupRange(minValues, maxValues)
-	return s.collectDocumentResults(docIDs), nil
+	return s.collectDocumentResults(ctx, docIDs)
 }
 
-// collectDocumentResults converts document IDs to results.
-func (s *Store) collectDocumentResults(docIDs []string) []*DocumentResult {
+// cancellationCheckInterval is how many documents are materialized between
+// checks of the caller's context.
+const cancellationCheckInterval = 64
+
+// collectDocumentResults converts document IDs to results, leaving out expired
+// documents and checking ctx periodically so long materializations can be
+// abandoned.
+func (s *Store) collectDocumentResults(ctx context.Context, docIDs []string) ([]*DocumentResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	results := make([]*DocumentResult, 0, len(docIDs))
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for _, docID := range docIDs {
-		if entry, exists := s.handles[docID]; exists {
-			if doc, exists := s.collection.Get(entry.handle.index); exists {
+	now := time.Now()
+	for i, docID := range docIDs {
+		if i%cancellationCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		if handle, live := s.liveHandle(docID, now); live {
+			if doc, exists := s.collection.Get(handle.index); exists {
+				if lru := s.lru.Load(); lru != nil {
+					lru.touch(docID)
+				}
 				results = append(results, &DocumentResult{
-					ID:      docID,
-					Data:    doc.data,
-					Version: doc.version,
+					ID:        docID,
+					Data:      doc.data,
+					Version:   doc.version,
+					CreatedAt: doc.createdAt,
+					UpdatedAt: doc.updatedAt,
 				})
 			}
 		}
 	}
 
-	return results
+	return results, nil
+}
+
+// ensureOpen reports ErrStoreNil for a nil receiver, so methods called on an
+// uninitialized *Store fail cleanly instead of panicking, and ErrStoreClosed
+// once the store has been closed.
+func (s *Store) ensureOpen() error {
+	if s == nil {
+		return ErrStoreNil
+	}
+	if s.closed.Load() {
+		return ErrStoreClosed
+	}
+	return nil
 }
 
 // Close shuts down the store and releases all resources.
+// Closing a nil store is a no-op.
 func (s *Store) Close() {
-	s.closed.Store(true)
+	if s == nil {
+		return
+	}
+	if s.closed.Swap(true) {
+		return // Already closed
+	}
+
+	// Stop the TTL sweeper before tearing down the state it works on
+	close(s.sweepStop)
+	<-s.sweepDone
+
+	s.closeWatchers()
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Clear maps to help garbage collection
 	clear(s.handles)
+	s.readHandles.Clear()
 	clear(s.indexes)
+	clear(s.expiring)
+	clear(s.trash)
+	s.lru.Store(nil)
+	s.tombstones = nil
 }
 
 // copyDocument creates a deep copy of a document.
@@ -960,6 +3688,24 @@ func copyValue(src any) any {
 		dst := make([]string, len(v))
 		copy(dst, v)
 		return dst
+	case []int64:
+		dst := make([]int64, len(v))
+		copy(dst, v)
+		return dst
+	case []float64:
+		dst := make([]float64, len(v))
+		copy(dst, v)
+		return dst
+	case []bool:
+		dst := make([]bool, len(v))
+		copy(dst, v)
+		return dst
+	case []map[string]any:
+		dst := make([]map[string]any, len(v))
+		for i, elem := range v {
+			dst[i] = copyDocument(elem)
+		}
+		return dst
 	default:
 		// For primitive types, direct assignment is sufficient
 		return v
@@ -1000,8 +3746,21 @@ func compareValues(a, b any) int {
 	return 0
 }
 
-// compareNumbers compares two numeric values.
+// compareNumbers compares two numeric values. Integers are compared exactly
+// without going through float64, which cannot represent every int64 above 2^53.
 func compareNumbers(a, b any) int {
+	intA, aIsInt := toInt64(a)
+	intB, bIsInt := toInt64(b)
+
+	switch {
+	case aIsInt && bIsInt:
+		return cmp.Compare(intA, intB)
+	case aIsInt:
+		return compareIntFloat(intA, toFloat64(b))
+	case bIsInt:
+		return -compareIntFloat(intB, toFloat64(a))
+	}
+
 	valA := toFloat64(a)
 	valB := toFloat64(b)
 
@@ -1013,6 +3772,31 @@ func compareNumbers(a, b any) int {
 	return 0
 }
 
+// compareIntFloat compares an integer with a float without losing precision.
+func compareIntFloat(i int64, f float64) int {
+	switch {
+	case math.IsNaN(f):
+		return 0 // Unordered; treat as equal like the float comparison does
+	case f < math.MinInt64:
+		return 1
+	case f >= math.MaxInt64: // float64(MaxInt64) rounds up to 2^63
+		return -1
+	}
+
+	// f is within int64 range: compare integer parts exactly, then let the
+	// fractional part break ties
+	whole := math.Trunc(f)
+	if c := cmp.Compare(i, int64(whole)); c != 0 {
+		return c
+	}
+	if f > whole {
+		return -1
+	} else if f < whole {
+		return 1
+	}
+	return 0
+}
+
 // compareSameType compares two values of the same type.
 func compareSameType(a, b any) int {
 	switch va := a.(type) {
@@ -1066,6 +3850,21 @@ func toFloat64(v any) float64 {
 	}
 }
 
+// toInt64 converts an integer value to int64, reporting false for
+// non-integer types.
+func toInt64(v any) (int64, bool) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	default:
+		return 0, false
+	}
+}
+
 // isNumber checks if a value is a numeric type.
 func isNumber(v any) bool {
 	switch v.(type) {
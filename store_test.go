@@ -1,9 +1,18 @@
 package gostore
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -258,16 +267,15 @@ func TestLookupRange(t *testing.T) {
 	}
 	_, _ = s.Insert(map[string]any{"item": "Special", "score": 5.5}) // Float value
 
-	// Test range lookup [3, 6] -> should include 3, 4, 5, 5.5, 6
+	// LookupRange includes the minimum and excludes the maximum:
+	// [3, 6) -> 3, 4, 5, 5.5. See LookupRangeBounds for other combinations.
 	results, err := s.LookupRange("by_score", []any{3}, []any{6})
 	if err != nil {
 		t.Fatalf("LookupRange failed: %v", err)
 	}
 
-	// The original test assumed an exclusive upper bound, but AscendRange is inclusive.
-	// Let's correct the test to expect 5 results: 3, 4, 5, 5.5, 6.
 	if len(results) != 4 {
-		t.Errorf("Expected 5 documents for range [3, 6], got %d", len(results))
+		t.Errorf("Expected 4 documents for range [3, 6), got %d", len(results))
 	}
 
 	// Verify content
@@ -787,6 +795,89 @@ func TestEdge_StreamWithZeroBuffer(t *testing.T) {
 	}
 }
 
+// TestEdge_StreamSlowConsumer verifies that a full buffer applies backpressure
+// instead of dropping documents.
+func TestEdge_StreamSlowConsumer(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	const total = 50
+	for i := range total {
+		_, _ = s.Insert(map[string]any{"num": i})
+	}
+
+	stream := s.Stream(4)
+	defer stream.Close()
+
+	seen := make(map[string]bool)
+	for {
+		doc, err := stream.Next()
+		if err == ErrStreamClosed {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		seen[doc.ID] = true
+		time.Sleep(time.Millisecond) // Let the producer fill the buffer
+	}
+
+	if len(seen) != total {
+		t.Errorf("Expected all %d documents, got %d", total, len(seen))
+	}
+}
+
+// TestEdge_StreamConcurrentUpdates verifies that streamed documents are
+// independent copies, so updating the store while a stream is drained, and
+// modifying streamed data, are race-free. Run with -race.
+func TestEdge_StreamConcurrentUpdates(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i], _ = s.Insert(map[string]any{"n": i, "tags": []any{"a"}, "meta": map[string]any{"v": 0}})
+	}
+
+	stream := s.Stream(1)
+	defer stream.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for round := 1; round <= 5; round++ {
+			for i, id := range ids {
+				_ = s.Update(id, map[string]any{"n": i, "tags": []any{"b"}, "meta": map[string]any{"v": round}})
+			}
+		}
+	}()
+
+	streamed := 0
+	for {
+		doc, err := stream.Next()
+		if err == ErrStreamClosed {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		streamed++
+		doc.Data["meta"].(map[string]any)["v"] = -1
+		doc.Data["tags"].([]any)[0] = "mutated"
+	}
+	<-done
+
+	if streamed != len(ids) {
+		t.Errorf("Expected %d documents, got %d", len(ids), streamed)
+	}
+	for _, id := range ids {
+		doc, _ := s.Get(id)
+		if doc.Data["meta"].(map[string]any)["v"] != 5 || doc.Data["tags"].([]any)[0] != "b" {
+			t.Fatalf("Expected streamed copies not to alias stored data, got %v", doc.Data)
+		}
+	}
+}
+
 // TestEdge_StreamCancellation verifies that a blocking Next() call can be cancelled.
 func TestEdge_StreamCancellation(t *testing.T) {
 	s := NewStore()
@@ -969,3 +1060,3010 @@ func TestConcurrency_DeleteAndUpdate(t *testing.T) {
 		})
 	}
 }
+
+// TestReindexDocument tests repairing a single document's drifted index entries.
+func TestReindexDocument(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city", []string{"city"})
+	driftedID, _ := s.Insert(map[string]any{"city": "NYC", "name": "Alice"})
+	otherID, _ := s.Insert(map[string]any{"city": "NYC", "name": "Bob"})
+
+	// Corrupt the index: file the document under the wrong key
+	idx := s.indexes["by_city"]
+	idx.mu.Lock()
+	idx.removeFromIndex(driftedID, []any{"NYC"})
+	idx.addToIndex(driftedID, []any{"LA"})
+	idx.mu.Unlock()
+
+	if results, _ := s.Lookup("by_city", []any{"LA"}); len(results) != 1 {
+		t.Fatalf("Expected corrupted index to report 1 LA document, got %d", len(results))
+	}
+
+	if err := s.ReindexDocument(driftedID); err != nil {
+		t.Fatalf("ReindexDocument failed: %v", err)
+	}
+
+	if results, _ := s.Lookup("by_city", []any{"LA"}); len(results) != 0 {
+		t.Errorf("Expected no LA documents after reindex, got %d", len(results))
+	}
+
+	results, _ := s.Lookup("by_city", []any{"NYC"})
+	ids := map[string]bool{}
+	for _, doc := range results {
+		ids[doc.ID] = true
+	}
+	if len(ids) != 2 || !ids[driftedID] || !ids[otherID] {
+		t.Errorf("Expected both documents under NYC after reindex, got %v", ids)
+	}
+
+	if err := s.ReindexDocument("missing"); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+// TestInsertBatch tests inserting multiple documents at once.
+func TestInsertBatch(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_group", []string{"group"})
+
+	docs := []map[string]any{
+		{"group": "A", "n": 1},
+		{"group": "B", "n": 2},
+		{"group": "A", "n": 3},
+	}
+	ids, err := s.InsertBatch(docs)
+	if err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if len(ids) != len(docs) {
+		t.Fatalf("Expected %d IDs, got %d", len(docs), len(ids))
+	}
+
+	// IDs are returned in input order
+	for i, id := range ids {
+		doc, err := s.Get(id)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", id, err)
+		}
+		if doc.Data["n"] != docs[i]["n"] {
+			t.Errorf("ID %d maps to wrong document: %v", i, doc.Data)
+		}
+	}
+
+	if results, _ := s.Lookup("by_group", []any{"A"}); len(results) != 2 {
+		t.Errorf("Expected 2 documents in group A, got %d", len(results))
+	}
+
+	// A nil document rejects the whole batch
+	_, err = s.InsertBatch([]map[string]any{{"group": "C"}, nil})
+	if err != ErrInvalidDocument {
+		t.Errorf("Expected ErrInvalidDocument, got %v", err)
+	}
+	if results, _ := s.Lookup("by_group", []any{"C"}); len(results) != 0 {
+		t.Errorf("Expected no partial insert, got %d documents", len(results))
+	}
+}
+
+func benchmarkDocs(n int) []map[string]any {
+	docs := make([]map[string]any, n)
+	for i := range docs {
+		docs[i] = map[string]any{"group": i % 10, "score": i, "name": fmt.Sprintf("doc-%d", i)}
+	}
+	return docs
+}
+
+func BenchmarkInsertLoop(b *testing.B) {
+	docs := benchmarkDocs(1000)
+	for b.Loop() {
+		s := NewStore()
+		_ = s.CreateIndex("by_group", []string{"group"})
+		_ = s.CreateIndex("by_score", []string{"score"})
+		for _, doc := range docs {
+			_, _ = s.Insert(doc)
+		}
+		s.Close()
+	}
+}
+
+func BenchmarkInsertBatch(b *testing.B) {
+	docs := benchmarkDocs(1000)
+	for b.Loop() {
+		s := NewStore()
+		_ = s.CreateIndex("by_group", []string{"group"})
+		_ = s.CreateIndex("by_score", []string{"score"})
+		_, _ = s.InsertBatch(docs)
+		s.Close()
+	}
+}
+
+// BenchmarkIndexDegree compares insert and lookup throughput across B-tree
+// degrees so the degree can be tuned for a workload.
+func BenchmarkIndexDegree(b *testing.B) {
+	docs := benchmarkDocs(10000)
+
+	for _, degree := range []int{2, 8, 32, 128} {
+		b.Run(fmt.Sprintf("insert/degree=%d", degree), func(b *testing.B) {
+			for b.Loop() {
+				s := NewStore()
+				_ = s.CreateIndexWithDegree("by_score", []string{"score"}, degree)
+				_, _ = s.InsertBatch(docs)
+				s.Close()
+			}
+		})
+
+		b.Run(fmt.Sprintf("lookup/degree=%d", degree), func(b *testing.B) {
+			s := NewStore()
+			defer s.Close()
+			_ = s.CreateIndexWithDegree("by_score", []string{"score"}, degree)
+			_, _ = s.InsertBatch(docs)
+
+			index := s.indexes["by_score"]
+			i := 0
+			for b.Loop() {
+				_ = index.lookup([]any{i % len(docs)})
+				i++
+			}
+		})
+	}
+}
+
+// BenchmarkGetConcurrentWrites compares Get's lock-free handle lookup with
+// resolving the handle under the store's lock while a writer keeps updating.
+func BenchmarkGetConcurrentWrites(b *testing.B) {
+	s := NewStore()
+	defer s.Close()
+	_ = s.CreateIndex("by_score", []string{"score"})
+	ids, _ := s.InsertBatch(benchmarkDocs(10000))
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = s.Update(ids[i%len(ids)], map[string]any{"score": i})
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	for name, get := range map[string]func(string) (*DocumentResult, error){
+		"lockfree": s.Get,
+		"locked":   func(docID string) (*DocumentResult, error) { return s.getLocked(docID, true) },
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					_, _ = get(ids[i%len(ids)])
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkGetRef compares Get, which deep copies each document, with
+// GetRef, which shares the stored data, on a document with nested fields.
+func BenchmarkGetRef(b *testing.B) {
+	s := NewStore()
+	defer s.Close()
+
+	doc := map[string]any{"name": "Alice"}
+	for i := range 50 {
+		doc[fmt.Sprintf("field%d", i)] = map[string]any{"tags": []any{"a", "b", "c"}, "n": i}
+	}
+	id, _ := s.Insert(doc)
+
+	for name, get := range map[string]func(string) (*DocumentResult, error){
+		"copy": s.Get,
+		"ref":  s.GetRef,
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				_, _ = get(id)
+			}
+		})
+	}
+}
+
+// TestGetRef tests that GetRef returns the stored document without copying,
+// and that later updates leave a previously returned result unchanged.
+func TestGetRef(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	id, _ := s.Insert(map[string]any{"name": "Alice", "tags": []any{"a", "b"}})
+
+	ref, err := s.GetRef(id)
+	if err != nil {
+		t.Fatalf("GetRef failed: %v", err)
+	}
+	if ref.Data["name"] != "Alice" || ref.Version != 1 {
+		t.Fatalf("unexpected result: %+v", ref)
+	}
+
+	again, _ := s.GetRef(id)
+	if reflect.ValueOf(ref.Data).UnsafePointer() != reflect.ValueOf(again.Data).UnsafePointer() {
+		t.Error("expected GetRef results to share the stored data")
+	}
+	if got, _ := s.Get(id); reflect.ValueOf(got.Data).UnsafePointer() == reflect.ValueOf(ref.Data).UnsafePointer() {
+		t.Error("expected Get to return a copy")
+	}
+
+	if err := s.Update(id, map[string]any{"name": "Bob"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if ref.Data["name"] != "Alice" {
+		t.Errorf("expected earlier result to be unchanged by Update, got %v", ref.Data["name"])
+	}
+	if current, _ := s.GetRef(id); current.Data["name"] != "Bob" || current.Version != 2 {
+		t.Errorf("expected updated document, got %+v", current)
+	}
+
+	if err := s.Delete(id); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.GetRef(id); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("expected ErrDocumentNotFound after delete, got %v", err)
+	}
+}
+
+// TestGetConcurrentWithCompact tests that Get never returns another
+// document's data while handles are being moved and removed.
+func TestGetConcurrentWithCompact(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	ids := make([]string, 200)
+	for i := range ids {
+		ids[i], _ = s.Insert(map[string]any{"n": i})
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				n := i % len(ids)
+				if doc, err := s.Get(ids[n]); err == nil && doc.Data["n"] != n {
+					t.Errorf("Expected n=%d for %s, got %v", n, ids[n], doc.Data["n"])
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < len(ids); i += 2 {
+		_ = s.Delete(ids[i])
+		if i%20 == 0 {
+			_ = s.Compact()
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	for i, id := range ids {
+		_, err := s.Get(id)
+		if deleted := i%2 == 0; deleted != errors.Is(err, ErrDocumentNotFound) {
+			t.Errorf("Document %d: expected deleted=%v, got %v", i, deleted, err)
+		}
+	}
+}
+
+// TestCreateIndexWithDegree tests indexes built with a custom B-tree degree.
+func TestCreateIndexWithDegree(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	if err := s.CreateIndexWithDegree("by_score", []string{"score"}, 2); err != nil {
+		t.Fatalf("CreateIndexWithDegree failed: %v", err)
+	}
+	for _, doc := range benchmarkDocs(100) {
+		_, _ = s.Insert(doc)
+	}
+
+	if results, _ := s.LookupRange("by_score", []any{10}, []any{20}); len(results) != 10 {
+		t.Errorf("Expected 10 results, got %d", len(results))
+	}
+
+	clone, _ := s.Clone()
+	defer clone.Close()
+	if degree := clone.indexes["by_score"].degree; degree != 2 {
+		t.Errorf("Expected clone to keep degree 2, got %d", degree)
+	}
+
+	if err := s.CreateIndexWithDegree("bad", []string{"score"}, 1); err != ErrInvalidDegree {
+		t.Errorf("Expected ErrInvalidDegree, got %v", err)
+	}
+	if s.HasIndex("bad") {
+		t.Error("Expected invalid index not to be created")
+	}
+}
+
+// TestKeyEqualitySkipsNumericChurn tests that the default numeric-aware key
+// equality leaves the index entry untouched when only the numeric type changes.
+func TestKeyEqualitySkipsNumericChurn(t *testing.T) {
+	entryFor := func(s *Store, key any) indexEntry {
+		item := s.indexes["by_score"].tree.Get(indexEntry{key: indexKey{values: []any{key}}})
+		if item == nil {
+			t.Fatalf("No index entry for key %v", key)
+		}
+		return item.(indexEntry)
+	}
+
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_score", []string{"score"})
+	id, _ := s.Insert(map[string]any{"score": 5})
+	before := entryFor(s, 5)
+
+	if err := s.Update(id, map[string]any{"score": 5.0}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	after := entryFor(s, 5.0)
+	if reflect.ValueOf(before.docIDs).Pointer() != reflect.ValueOf(after.docIDs).Pointer() {
+		t.Error("Expected the original index entry to be reused")
+	}
+	if _, isInt := after.key.values[0].(int); !isInt {
+		t.Errorf("Expected untouched int key, got %T", after.key.values[0])
+	}
+
+	// A strict equality function forces the entry to be rebuilt
+	s.SetKeyEquality(func(a, b []any) bool { return reflect.DeepEqual(a, b) })
+	if err := s.Update(id, map[string]any{"score": 5}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	rebuilt := entryFor(s, 5)
+	if reflect.ValueOf(after.docIDs).Pointer() == reflect.ValueOf(rebuilt.docIDs).Pointer() {
+		t.Error("Expected strict equality to rebuild the index entry")
+	}
+	if results, _ := s.Lookup("by_score", []any{5}); len(results) != 1 {
+		t.Errorf("Expected 1 document after rebuild, got %d", len(results))
+	}
+}
+
+// TestTrimMemory tests that trimming releases slice capacity after a delete burst.
+func TestTrimMemory(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i], _ = s.Insert(map[string]any{"n": i})
+	}
+
+	// Delete the tail and punch a few holes in the head
+	for i, id := range ids {
+		if i >= 100 || i%10 == 0 {
+			_ = s.Delete(id)
+		}
+	}
+
+	s.TrimMemory()
+
+	c := s.collection
+	if len(c.documents) != 100 || cap(c.documents) != len(c.documents) {
+		t.Errorf("Expected documents len=cap=100, got len=%d cap=%d", len(c.documents), cap(c.documents))
+	}
+	if len(c.freeSlots) != 10 || cap(c.freeSlots) != len(c.freeSlots) {
+		t.Errorf("Expected freeSlots len=cap=10, got len=%d cap=%d", len(c.freeSlots), cap(c.freeSlots))
+	}
+
+	for i := 0; i < 100; i++ {
+		doc, err := s.Get(ids[i])
+		if i%10 == 0 {
+			if err != ErrDocumentNotFound {
+				t.Errorf("Expected deleted document %d to stay deleted, got %v", i, err)
+			}
+			continue
+		}
+		if err != nil || doc.Data["n"] != i {
+			t.Errorf("Document %d not intact after trim: %v, %v", i, doc, err)
+		}
+	}
+
+	// Freed slots remain reusable
+	id, _ := s.Insert(map[string]any{"n": "new"})
+	if doc, err := s.Get(id); err != nil || doc.Data["n"] != "new" {
+		t.Errorf("Insert after trim failed: %v, %v", doc, err)
+	}
+}
+
+// TestDeleteBatch tests deleting multiple documents at once.
+func TestDeleteBatch(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_group", []string{"group"})
+
+	ids, _ := s.InsertBatch([]map[string]any{
+		{"group": "A"}, {"group": "A"}, {"group": "B"},
+	})
+
+	deleted, err := s.DeleteBatch([]string{ids[0], "missing", ids[2], ids[0]})
+	if err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deletions, got %d", deleted)
+	}
+
+	if _, err := s.Get(ids[1]); err != nil {
+		t.Errorf("Expected surviving document to remain, got %v", err)
+	}
+	if results, _ := s.Lookup("by_group", []any{"A"}); len(results) != 1 {
+		t.Errorf("Expected 1 document in group A, got %d", len(results))
+	}
+	if results, _ := s.Lookup("by_group", []any{"B"}); len(results) != 0 {
+		t.Errorf("Expected no documents in group B, got %d", len(results))
+	}
+}
+
+// TestLookupCtx tests context-aware lookups.
+func TestLookupCtx(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_score", []string{"score"})
+	for i := range 200 {
+		_, _ = s.Insert(map[string]any{"score": i % 5})
+	}
+
+	results, err := s.LookupCtx(context.Background(), "by_score", []any{1})
+	if err != nil || len(results) != 40 {
+		t.Errorf("Expected 40 results, got %d (err=%v)", len(results), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.LookupCtx(ctx, "by_score", []any{1}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from LookupCtx, got %v", err)
+	}
+	if _, err := s.LookupRangeCtx(ctx, "by_score", []any{0}, []any{5}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from LookupRangeCtx, got %v", err)
+	}
+
+	// Cancellation is also observed while results are being materialized
+	docIDs := s.indexes["by_score"].lookupRange([]any{0}, []any{5})
+	ctx, cancel = context.WithCancel(context.Background())
+	calls := 0
+	_, err = s.collectDocumentResults(&cancelAfterContext{Context: ctx, cancel: cancel, after: 2, calls: &calls}, docIDs)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled mid-collection, got %v", err)
+	}
+}
+
+// cancelAfterContext cancels itself after Err has been consulted a set number of times.
+type cancelAfterContext struct {
+	context.Context
+	cancel context.CancelFunc
+	after  int
+	calls  *int
+}
+
+func (c *cancelAfterContext) Err() error {
+	*c.calls++
+	if *c.calls > c.after {
+		c.cancel()
+	}
+	return c.Context.Err()
+}
+
+// TestStreamRangeFilter tests streaming a filtered index range.
+func TestStreamRangeFilter(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_score", []string{"score"})
+	for i := range 20 {
+		_, _ = s.Insert(map[string]any{"score": i, "even": i%2 == 0})
+	}
+
+	isEven := func(doc map[string]any) bool { return doc["even"] == true }
+
+	stream, err := s.StreamRangeFilter("by_score", []any{5}, []any{15}, isEven, 2)
+	if err != nil {
+		t.Fatalf("StreamRangeFilter failed: %v", err)
+	}
+
+	var scores []int
+	for {
+		doc, err := stream.Next()
+		if err == ErrStreamClosed {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading from stream: %v", err)
+		}
+		scores = append(scores, doc.Data["score"].(int))
+	}
+	sort.Ints(scores)
+
+	if expected := []int{6, 8, 10, 12, 14}; !reflect.DeepEqual(scores, expected) {
+		t.Errorf("Expected scores %v, got %v", expected, scores)
+	}
+
+	// Cancelling the stream stops delivery
+	stream, _ = s.StreamRangeFilter("by_score", []any{0}, []any{20}, nil, 0)
+	if _, err := stream.Next(); err != nil {
+		t.Fatalf("First Next failed: %v", err)
+	}
+	stream.Close()
+	time.Sleep(10 * time.Millisecond)
+
+	received := 0
+	for {
+		if _, err := stream.Next(); err != nil {
+			break
+		}
+		received++
+	}
+	if received > 1 {
+		t.Errorf("Expected cancelled stream to stop, received %d more documents", received)
+	}
+
+	if _, err := s.StreamRangeFilter("missing", nil, nil, nil, 0); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestDeleteByIndex tests deleting all documents matching an index value.
+func TestDeleteByIndex(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_tenant", []string{"tenant"})
+	_ = s.CreateIndex("by_role", []string{"role"})
+
+	_, _ = s.Insert(map[string]any{"tenant": "acme", "role": "admin"})
+	_, _ = s.Insert(map[string]any{"tenant": "acme", "role": "user"})
+	keep, _ := s.Insert(map[string]any{"tenant": "globex", "role": "admin"})
+
+	deleted, err := s.DeleteByIndex("by_tenant", []any{"acme"})
+	if err != nil {
+		t.Fatalf("DeleteByIndex failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deletions, got %d", deleted)
+	}
+
+	// Other indexes no longer reference the deleted documents
+	admins, _ := s.Lookup("by_role", []any{"admin"})
+	if len(admins) != 1 || admins[0].ID != keep {
+		t.Errorf("Expected only the surviving admin, got %v", admins)
+	}
+	if users, _ := s.Lookup("by_role", []any{"user"}); len(users) != 0 {
+		t.Errorf("Expected no users, got %d", len(users))
+	}
+
+	if deleted, _ := s.DeleteByIndex("by_tenant", []any{"acme"}); deleted != 0 {
+		t.Errorf("Expected 0 deletions on second call, got %d", deleted)
+	}
+	if _, err := s.DeleteByIndex("missing", []any{"x"}); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestCompareAndSetField tests single-field compare-and-set semantics.
+func TestCompareAndSetField(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_state", []string{"state"})
+	id, _ := s.Insert(map[string]any{"state": "pending", "count": 1})
+
+	swapped, err := s.CompareAndSetField(id, "state", "active", "done")
+	if err != nil || swapped {
+		t.Errorf("Expected failed swap on mismatch, got swapped=%v err=%v", swapped, err)
+	}
+
+	// Numerically equal values match across types
+	swapped, _ = s.CompareAndSetField(id, "count", 1.0, 2)
+	if !swapped {
+		t.Error("Expected 1.0 to match stored 1")
+	}
+
+	// A missing field matches nil
+	swapped, _ = s.CompareAndSetField(id, "owner", nil, "alice")
+	if !swapped {
+		t.Error("Expected missing field to match nil")
+	}
+
+	doc, _ := s.Get(id)
+	if doc.Data["count"] != 2 || doc.Data["owner"] != "alice" || doc.Version != 3 {
+		t.Errorf("Unexpected document after swaps: %+v", doc)
+	}
+
+	if _, err := s.CompareAndSetField("missing", "state", nil, "x"); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+// TestCompareAndSetFieldConcurrent tests that exactly one concurrent CAS wins.
+func TestCompareAndSetFieldConcurrent(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_state", []string{"state"})
+	id, _ := s.Insert(map[string]any{"state": "pending"})
+
+	const workers = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := []string{}
+
+	for i := range workers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := fmt.Sprintf("claimed-by-%d", i)
+			swapped, err := s.CompareAndSetField(id, "state", "pending", value)
+			if err != nil {
+				t.Errorf("CompareAndSetField failed: %v", err)
+			}
+			if swapped {
+				mu.Lock()
+				winners = append(winners, value)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(winners) != 1 {
+		t.Fatalf("Expected exactly one winner, got %d", len(winners))
+	}
+
+	doc, _ := s.Get(id)
+	if doc.Data["state"] != winners[0] {
+		t.Errorf("Expected state %q, got %v", winners[0], doc.Data["state"])
+	}
+	if results, _ := s.Lookup("by_state", []any{winners[0]}); len(results) != 1 {
+		t.Errorf("Expected index to reflect the winning value, got %d results", len(results))
+	}
+}
+
+// TestCreatePartialIndex tests that only documents passing the filter are indexed.
+func TestCreatePartialIndex(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	isActive := func(doc map[string]any) bool { return doc["status"] == "active" }
+
+	activeID, _ := s.Insert(map[string]any{"city": "NYC", "status": "active"})
+	inactiveID, _ := s.Insert(map[string]any{"city": "NYC", "status": "inactive"})
+
+	if err := s.CreatePartialIndex("active_by_city", []string{"city"}, isActive); err != nil {
+		t.Fatalf("CreatePartialIndex failed: %v", err)
+	}
+
+	results, _ := s.Lookup("active_by_city", []any{"NYC"})
+	if len(results) != 1 || results[0].ID != activeID {
+		t.Fatalf("Expected only the active document, got %v", results)
+	}
+
+	// Documents leave and enter the index as their data changes
+	_ = s.Update(activeID, map[string]any{"city": "NYC", "status": "inactive"})
+	_ = s.Update(inactiveID, map[string]any{"city": "NYC", "status": "active"})
+
+	results, _ = s.Lookup("active_by_city", []any{"NYC"})
+	if len(results) != 1 || results[0].ID != inactiveID {
+		t.Errorf("Expected only the newly active document, got %v", results)
+	}
+
+	// Clones keep the filter
+	clone, err := s.Clone()
+	if err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+	defer clone.Close()
+
+	_, _ = clone.Insert(map[string]any{"city": "NYC", "status": "inactive"})
+	if results, _ := clone.Lookup("active_by_city", []any{"NYC"}); len(results) != 1 {
+		t.Errorf("Expected cloned partial index to hold 1 document, got %d", len(results))
+	}
+
+	if err := s.CreatePartialIndex("empty", nil, isActive); err != ErrEmptyIndex {
+		t.Errorf("Expected ErrEmptyIndex, got %v", err)
+	}
+}
+
+// TestCountConcurrent tests that the document counter stays accurate under
+// a concurrent mix of inserts and deletes.
+func TestCountConcurrent(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	const workers = 8
+	const perWorker = 200
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				id, err := s.Insert(map[string]any{"n": i})
+				if err != nil {
+					t.Errorf("Insert failed: %v", err)
+					return
+				}
+				if i%2 == 0 {
+					_ = s.Delete(id)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	expected := workers * perWorker / 2
+	if got := s.Count(); got != expected {
+		t.Errorf("Expected Count %d, got %d", expected, got)
+	}
+	if live := len(s.collection.GetAllValid()); live != s.Count() {
+		t.Errorf("Count %d does not match %d live documents", s.Count(), live)
+	}
+}
+
+// TestLookupAny tests unions of exact lookups.
+func TestLookupAny(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_country", []string{"country"})
+	_, _ = s.Insert(map[string]any{"country": "USA"})
+	_, _ = s.Insert(map[string]any{"country": "USA"})
+	_, _ = s.Insert(map[string]any{"country": "UK"})
+	_, _ = s.Insert(map[string]any{"country": "France"})
+
+	results, err := s.LookupAny("by_country", [][]any{{"USA"}, {"UK"}, {"USA"}, {"Spain"}})
+	if err != nil {
+		t.Fatalf("LookupAny failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 distinct documents, got %d", len(results))
+	}
+
+	seen := map[string]bool{}
+	for _, doc := range results {
+		if seen[doc.ID] {
+			t.Errorf("Duplicate document %s in results", doc.ID)
+		}
+		seen[doc.ID] = true
+		if c := doc.Data["country"]; c != "USA" && c != "UK" {
+			t.Errorf("Unexpected country %v", c)
+		}
+	}
+
+	if results, _ := s.LookupAny("by_country", nil); len(results) != 0 {
+		t.Errorf("Expected no results for empty value sets, got %d", len(results))
+	}
+	if _, err := s.LookupAny("missing", [][]any{{"USA"}}); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestLookupIntersect tests intersecting exact and range conditions across indexes.
+func TestLookupIntersect(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city", []string{"city"})
+	_ = s.CreateIndex("by_age", []string{"age"})
+
+	match, _ := s.Insert(map[string]any{"city": "NYC", "age": 35})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "age": 25})
+	_, _ = s.Insert(map[string]any{"city": "LA", "age": 40})
+
+	results, err := s.LookupIntersect([]IndexQuery{
+		{Index: "by_city", Values: []any{"NYC"}},
+		{Index: "by_age", Min: []any{31}, Max: []any{100}},
+	})
+	if err != nil {
+		t.Fatalf("LookupIntersect failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != match {
+		t.Errorf("Expected only the NYC document over 30, got %v", results)
+	}
+
+	results, _ = s.LookupIntersect([]IndexQuery{
+		{Index: "by_city", Values: []any{"LA"}},
+		{Index: "by_age", Values: []any{25}},
+	})
+	if len(results) != 0 {
+		t.Errorf("Expected empty intersection, got %d", len(results))
+	}
+
+	// Results come back sorted by ID, the same on every call
+	for range 5 {
+		_, _ = s.Insert(map[string]any{"city": "SF", "age": 40})
+	}
+	sfQuery := []IndexQuery{
+		{Index: "by_city", Values: []any{"SF"}},
+		{Index: "by_age", Values: []any{40}},
+	}
+	for range 10 {
+		results, _ := s.LookupIntersect(sfQuery)
+		if len(results) != 5 || !slices.IsSortedFunc(results, func(a, b *DocumentResult) int {
+			return strings.Compare(a.ID, b.ID)
+		}) {
+			t.Fatalf("Expected 5 results sorted by ID, got %v", results)
+		}
+	}
+
+	if results, _ := s.LookupIntersect(nil); len(results) != 0 {
+		t.Errorf("Expected no results for empty specs, got %d", len(results))
+	}
+	if _, err := s.LookupIntersect([]IndexQuery{{Index: "missing", Values: []any{1}}}); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestIndexStats tests index statistics reporting.
+func TestIndexStats(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city", []string{"city"})
+	for _, city := range []string{"NYC", "NYC", "NYC", "LA"} {
+		_, _ = s.Insert(map[string]any{"city": city})
+	}
+	_, _ = s.Insert(map[string]any{"name": "no city"})
+
+	stats, err := s.IndexStats("by_city")
+	if err != nil {
+		t.Fatalf("IndexStats failed: %v", err)
+	}
+
+	expected := map[string]any{
+		"entries":            2,
+		"total_docs":         4,
+		"fields":             []string{"city"},
+		"avg_docs_per_entry": 2.0,
+		"max_docs_per_entry": 3,
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("Expected %v, got %v", expected, stats)
+	}
+
+	if _, err := s.IndexStats("missing"); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestListIndexes tests enumerating indexes, including on a clone.
+func TestListIndexes(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	if infos := s.ListIndexes(); len(infos) != 0 {
+		t.Errorf("Expected no indexes, got %v", infos)
+	}
+
+	_ = s.CreateIndex("by_name", []string{"name"})
+	_ = s.CreateIndex("by_city_age", []string{"city", "age"})
+
+	expected := []IndexInfo{
+		{Name: "by_city_age", Fields: []string{"city", "age"}},
+		{Name: "by_name", Fields: []string{"name"}},
+	}
+
+	infos := s.ListIndexes()
+	if !reflect.DeepEqual(infos, expected) {
+		t.Errorf("Expected %v, got %v", expected, infos)
+	}
+
+	// Mutating the result must not affect the store
+	infos[0].Fields[0] = "mutated"
+	if !reflect.DeepEqual(s.ListIndexes(), expected) {
+		t.Error("ListIndexes exposed internal state")
+	}
+
+	clone, _ := s.Clone()
+	defer clone.Close()
+	if !reflect.DeepEqual(clone.ListIndexes(), expected) {
+		t.Errorf("Clone did not reconstruct indexes: %v", clone.ListIndexes())
+	}
+}
+
+// TestLookupPrefix tests prefix queries on a composite index.
+func TestLookupPrefix(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city_age", []string{"city", "age"})
+	_, _ = s.Insert(map[string]any{"city": "LA", "age": 50})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "age": 30})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "age": 20})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "age": 30})
+	_, _ = s.Insert(map[string]any{"city": "SF", "age": 25})
+	_, _ = s.Insert(map[string]any{"city": "NYC"}) // Not indexed
+
+	tests := []struct {
+		prefix   []any
+		expected int
+	}{
+		{[]any{"NYC"}, 3},
+		{[]any{"NYC", 30}, 2},
+		{[]any{"Boston"}, 0},
+		{[]any{}, 5},
+		{nil, 5},
+		{[]any{"NYC", 30, "extra"}, 0},
+	}
+
+	for _, tt := range tests {
+		results, err := s.LookupPrefix("by_city_age", tt.prefix)
+		if err != nil {
+			t.Fatalf("LookupPrefix(%v) failed: %v", tt.prefix, err)
+		}
+		if len(results) != tt.expected {
+			t.Errorf("LookupPrefix(%v): expected %d documents, got %d", tt.prefix, tt.expected, len(results))
+		}
+	}
+
+	if _, err := s.LookupPrefix("missing", nil); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestCompareValuesLargeIntegers tests that integers beyond float64's exact
+// range keep their ordering.
+func TestCompareValuesLargeIntegers(t *testing.T) {
+	const big = int64(9007199254740993) // 2^53 + 1, not representable as float64
+
+	tests := []struct {
+		a, b     any
+		expected int
+	}{
+		{big, big - 1, 1},
+		{big - 1, big, -1},
+		{big, big, 0},
+		{big, 9007199254740992.0, 1},  // float64 2^53
+		{9007199254740992.0, big, -1}, // and reversed
+		{int32(5), int64(5), 0},
+		{5, 5.5, -1},
+		{-5, -5.5, 1},
+		{6, 5.5, 1},
+		{int64(math.MaxInt64), math.Pow(2, 63), -1},
+		{int64(math.MinInt64), -math.Pow(2, 64), 1},
+		{1.5, 2.5, -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.expected {
+			t.Errorf("compareValues(%v, %v): expected %d, got %d", tt.a, tt.b, tt.expected, got)
+		}
+	}
+}
+
+// TestIndexOrderingLargeIntegers tests that an index keeps large integer keys apart.
+func TestIndexOrderingLargeIntegers(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_id", []string{"external_id"})
+
+	const big = int64(9007199254740993)
+	_, _ = s.Insert(map[string]any{"external_id": big - 1})
+	_, _ = s.Insert(map[string]any{"external_id": big})
+	_, _ = s.Insert(map[string]any{"external_id": big + 1})
+
+	results, _ := s.Lookup("by_id", []any{big})
+	if len(results) != 1 || results[0].Data["external_id"] != big {
+		t.Errorf("Expected exactly the document with id %d, got %v", big, results)
+	}
+
+	results, _ = s.LookupRange("by_id", []any{big}, []any{big + 1})
+	if len(results) != 1 || results[0].Data["external_id"] != big {
+		t.Errorf("Expected range [big, big+1) to hold one document, got %v", results)
+	}
+}
+
+// TestLookupDeterministicOrder tests that documents sharing a key are returned
+// in a stable order, ascending by ID.
+func TestLookupDeterministicOrder(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_group", []string{"group"})
+
+	var groupA []string
+	for i := range 50 {
+		id, _ := s.Insert(map[string]any{"group": "A", "n": i})
+		groupA = append(groupA, id)
+	}
+	groupB, _ := s.Insert(map[string]any{"group": "B"})
+	sort.Strings(groupA)
+
+	ids := func(results []*DocumentResult) []string {
+		out := make([]string, len(results))
+		for i, doc := range results {
+			out[i] = doc.ID
+		}
+		return out
+	}
+
+	for range 5 {
+		results, _ := s.Lookup("by_group", []any{"A"})
+		if got := ids(results); !reflect.DeepEqual(got, groupA) {
+			t.Fatalf("Lookup order not sorted by ID: %v", got)
+		}
+
+		results, _ = s.LookupRange("by_group", []any{"A"}, []any{"C"})
+		if got := ids(results); !reflect.DeepEqual(got, append(slices.Clone(groupA), groupB)) {
+			t.Fatalf("LookupRange order not key-then-ID: %v", got)
+		}
+	}
+}
+
+// TestDocumentResultJSON tests the DocumentResult wire format and round trip.
+func TestDocumentResultJSON(t *testing.T) {
+	original := DocumentResult{
+		ID:      "doc-1",
+		Version: 7,
+		Data: map[string]any{
+			"name":   "Alice",
+			"score":  9.5,
+			"tags":   []any{"a", "b"},
+			"nested": map[string]any{"city": "NYC", "geo": map[string]any{"lat": 40.7}},
+		},
+	}
+
+	encoded, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var wire map[string]any
+	_ = json.Unmarshal(encoded, &wire)
+	if wire["id"] != "doc-1" || wire["version"] != 7.0 || wire["data"] == nil || len(wire) != 3 {
+		t.Errorf("Unexpected wire format: %s", encoded)
+	}
+
+	var decoded DocumentResult
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, original) {
+		t.Errorf("Round trip mismatch. Expected %+v, got %+v", original, decoded)
+	}
+
+	// Pointers and slices of results use the same format
+	encodedSlice, _ := json.Marshal([]*DocumentResult{&original})
+	if string(encodedSlice) != "["+string(encoded)+"]" {
+		t.Errorf("Unexpected slice encoding: %s", encodedSlice)
+	}
+}
+
+// TestEdge_DeepCopyTypedSlices verifies that typed slices are deep-copied too.
+func TestEdge_DeepCopyTypedSlices(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	input := map[string]any{
+		"floats":  []float64{1.5, 2.5},
+		"ints":    []int64{1, 2},
+		"flags":   []bool{true, false},
+		"records": []map[string]any{{"n": 1}},
+	}
+	id, _ := s.Insert(input)
+
+	// Mutating the caller's input must not leak into the store
+	input["floats"].([]float64)[0] = -1
+
+	retrieved, _ := s.Get(id)
+	retrieved.Data["floats"].([]float64)[1] = 99
+	retrieved.Data["ints"].([]int64)[0] = 99
+	retrieved.Data["flags"].([]bool)[0] = false
+	retrieved.Data["records"].([]map[string]any)[0]["n"] = 99
+
+	original, _ := s.Get(id)
+	expected := map[string]any{
+		"floats":  []float64{1.5, 2.5},
+		"ints":    []int64{1, 2},
+		"flags":   []bool{true, false},
+		"records": []map[string]any{{"n": 1}},
+	}
+	if !reflect.DeepEqual(original.Data, expected) {
+		t.Errorf("Stored document was modified through a copy: %v", original.Data)
+	}
+}
+
+// TestPatch tests partial updates, including field removal.
+func TestPatch(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city", []string{"city"})
+	_ = s.CreateIndex("by_age", []string{"age"})
+
+	id, _ := s.Insert(map[string]any{"name": "Alice", "city": "NYC", "age": 30})
+
+	err := s.Patch(id, map[string]any{"city": "LA", "age": DeleteField, "email": "a@example.com"})
+	if err != nil {
+		t.Fatalf("Patch failed: %v", err)
+	}
+
+	doc, _ := s.Get(id)
+	expected := map[string]any{"name": "Alice", "city": "LA", "email": "a@example.com"}
+	if !reflect.DeepEqual(doc.Data, expected) {
+		t.Errorf("Expected %v, got %v", expected, doc.Data)
+	}
+	if doc.Version != 2 {
+		t.Errorf("Expected version 2, got %d", doc.Version)
+	}
+
+	if results, _ := s.Lookup("by_city", []any{"LA"}); len(results) != 1 {
+		t.Errorf("Expected document under new city, got %d", len(results))
+	}
+	if results, _ := s.Lookup("by_city", []any{"NYC"}); len(results) != 0 {
+		t.Errorf("Expected document to leave old city, got %d", len(results))
+	}
+	if results, _ := s.LookupRange("by_age", []any{0}, []any{100}); len(results) != 0 {
+		t.Errorf("Expected document to leave age index, got %d", len(results))
+	}
+
+	if err := s.Patch("missing", map[string]any{"a": 1}); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+	if err := s.Patch(id, nil); err != ErrInvalidDocument {
+		t.Errorf("Expected ErrInvalidDocument, got %v", err)
+	}
+}
+
+// TestUnsetFields tests that unsetting an indexed field removes the document
+// from that index only.
+func TestUnsetFields(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_email", []string{"email"})
+	_ = s.CreateIndex("by_name", []string{"name"})
+
+	id, _ := s.Insert(map[string]any{"name": "Alice", "email": "a@example.com", "phone": "555"})
+
+	if err := s.UnsetFields(id, []string{"email", "phone"}); err != nil {
+		t.Fatalf("UnsetFields failed: %v", err)
+	}
+
+	doc, _ := s.Get(id)
+	if !reflect.DeepEqual(doc.Data, map[string]any{"name": "Alice"}) {
+		t.Errorf("Expected only name to remain, got %v", doc.Data)
+	}
+	if doc.Version != 2 {
+		t.Errorf("Expected version 2, got %d", doc.Version)
+	}
+
+	if results, _ := s.Lookup("by_email", []any{"a@example.com"}); len(results) != 0 {
+		t.Errorf("Expected document to drop out of email index, got %d", len(results))
+	}
+	if stats, _ := s.IndexStats("by_email"); stats["entries"] != 0 {
+		t.Errorf("Expected empty email index, got %v", stats)
+	}
+	if results, _ := s.Lookup("by_name", []any{"Alice"}); len(results) != 1 {
+		t.Errorf("Expected document to stay in name index, got %d", len(results))
+	}
+
+	if err := s.UnsetFields("missing", []string{"name"}); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+// TestReadAll tests that every live document is returned keyed by ID.
+func TestReadAll(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	id1, _ := s.Insert(map[string]any{"n": 1, "nested": map[string]any{"x": 1}})
+	id2, _ := s.Insert(map[string]any{"n": 2})
+	gone, _ := s.Insert(map[string]any{"n": 3})
+	trashed, _ := s.Insert(map[string]any{"n": 4})
+	_ = s.Delete(gone)
+	_ = s.SoftDelete(trashed)
+
+	all, err := s.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if len(all) != 2 || all[id1].Data["n"] != 1 || all[id2].Data["n"] != 2 {
+		t.Fatalf("Expected the two live documents, got %v", all)
+	}
+	if all[id2].ID != id2 || all[id2].Version == 0 {
+		t.Errorf("Expected IDs and versions to be filled in, got %+v", all[id2])
+	}
+
+	// The result is a deep copy
+	all[id1].Data["nested"].(map[string]any)["x"] = 99
+	if doc, _ := s.Get(id1); doc.Data["nested"].(map[string]any)["x"] != 1 {
+		t.Errorf("Expected the stored document to be unchanged, got %v", doc.Data)
+	}
+
+	s.Close()
+	if _, err := s.ReadAll(); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// TestGetMany tests resolving several IDs at once.
+func TestGetMany(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	id1, _ := s.Insert(map[string]any{"n": 1})
+	id2, _ := s.Insert(map[string]any{"n": 2})
+	deleted, _ := s.Insert(map[string]any{"n": 3})
+	_ = s.Delete(deleted)
+
+	results, err := s.GetMany([]string{id1, id2, deleted, "missing", id1})
+	if err != nil {
+		t.Fatalf("GetMany failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[id1].Data["n"] != 1 || results[id2].Data["n"] != 2 {
+		t.Errorf("Unexpected results: %v, %v", results[id1].Data, results[id2].Data)
+	}
+
+	s.Close()
+	if _, err := s.GetMany([]string{id1}); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// TestHasIndex tests checking for an index by name.
+func TestHasIndex(t *testing.T) {
+	s := NewStore()
+
+	if s.HasIndex("by_name") {
+		t.Error("Expected no index before creation")
+	}
+
+	_ = s.CreateIndex("by_name", []string{"name"})
+	if !s.HasIndex("by_name") {
+		t.Error("Expected index after creation")
+	}
+
+	_ = s.DropIndex("by_name")
+	if s.HasIndex("by_name") {
+		t.Error("Expected no index after drop")
+	}
+
+	_ = s.CreateIndex("by_name", []string{"name"})
+	s.Close()
+	if s.HasIndex("by_name") {
+		t.Error("Expected closed store to report no indexes")
+	}
+}
+
+// TestRebuildIndex tests repairing an index that has drifted out of sync.
+func TestRebuildIndex(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city", []string{"city"})
+
+	id1, _ := s.Insert(map[string]any{"city": "NYC"})
+	id2, _ := s.Insert(map[string]any{"city": "NYC"})
+	_, _ = s.Insert(map[string]any{"name": "no city"})
+
+	// Corrupt the index: drop one real entry and add a phantom one
+	index := s.indexes["by_city"]
+	index.mu.Lock()
+	index.removeFromIndex(id1, []any{"NYC"})
+	index.addToIndex("phantom", []any{"LA"})
+	index.mu.Unlock()
+
+	if err := s.RebuildIndex("by_city"); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+
+	results, _ := s.Lookup("by_city", []any{"NYC"})
+	if len(results) != 2 {
+		t.Errorf("Expected 2 NYC documents after rebuild, got %d", len(results))
+	}
+	if stats, _ := s.IndexStats("by_city"); stats["entries"] != 1 || stats["total_docs"] != 2 {
+		t.Errorf("Expected one entry holding two documents, got %v", stats)
+	}
+
+	for _, id := range []string{id1, id2} {
+		if indexes := s.handles[id].indexes; !reflect.DeepEqual(indexes, []string{"by_city"}) {
+			t.Errorf("Expected membership [by_city] for %s, got %v", id, indexes)
+		}
+	}
+
+	if err := s.RebuildIndex("missing"); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestCreateIndexWithTransform tests case-insensitive lookups through a
+// lowercasing index.
+func TestCreateIndexWithTransform(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	if err := s.CreateIndexWithTransform("by_email", []string{"email"}, LowerCaseTransform); err != nil {
+		t.Fatalf("CreateIndexWithTransform failed: %v", err)
+	}
+
+	id, _ := s.Insert(map[string]any{"email": "Alice@Example.com"})
+	_, _ = s.Insert(map[string]any{"email": "bob@example.com"})
+
+	results, err := s.Lookup("by_email", []any{"ALICE@example.COM"})
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != id {
+		t.Fatalf("Expected case-insensitive match, got %v", results)
+	}
+	if results[0].Data["email"] != "Alice@Example.com" {
+		t.Errorf("Expected stored value to keep its case, got %v", results[0].Data["email"])
+	}
+
+	if results, _ := s.LookupPrefix("by_email", []any{"BOB@EXAMPLE.COM"}); len(results) != 1 {
+		t.Errorf("Expected prefix lookup to apply the transform, got %d", len(results))
+	}
+	if results, _ := s.LookupRange("by_email", []any{"A"}, []any{"B"}); len(results) != 1 {
+		t.Errorf("Expected range lookup to apply the transform, got %d", len(results))
+	}
+
+	// Updates that only change case leave the key in place
+	_ = s.Update(id, map[string]any{"email": "ALICE@EXAMPLE.COM"})
+	if results, _ := s.Lookup("by_email", []any{"alice@example.com"}); len(results) != 1 {
+		t.Errorf("Expected match after case-only update, got %d", len(results))
+	}
+
+	// The transform survives cloning
+	clone, _ := s.Clone()
+	defer clone.Close()
+	if results, _ := clone.Lookup("by_email", []any{"Bob@Example.com"}); len(results) != 1 {
+		t.Errorf("Expected clone to keep the transform, got %d", len(results))
+	}
+
+	if LowerCaseTransform(42) != 42 {
+		t.Error("Expected non-string values to pass through unchanged")
+	}
+}
+
+// TestCreateFunctionalIndex tests indexing a value derived from each document.
+func TestCreateFunctionalIndex(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	total := func(doc map[string]any) (any, bool) {
+		price, ok1 := doc["price"].(int)
+		quantity, ok2 := doc["quantity"].(int)
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		return price * quantity, true
+	}
+	if err := s.CreateFunctionalIndex("by_total", total); err != nil {
+		t.Fatalf("CreateFunctionalIndex failed: %v", err)
+	}
+
+	id, _ := s.Insert(map[string]any{"price": 5, "quantity": 4})
+	_, _ = s.Insert(map[string]any{"price": 10, "quantity": 1})
+	_, _ = s.Insert(map[string]any{"price": 7}) // Excluded: no quantity
+
+	results, err := s.Lookup("by_total", []any{20})
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != id {
+		t.Fatalf("Expected document with total 20, got %v", results)
+	}
+
+	if stats, _ := s.IndexStats("by_total"); stats["total_docs"] != 2 {
+		t.Errorf("Expected 2 indexed documents, got %v", stats["total_docs"])
+	}
+
+	// Updates move the document to its new derived key
+	_ = s.Update(id, map[string]any{"price": 5, "quantity": 6})
+	if results, _ := s.Lookup("by_total", []any{20}); len(results) != 0 {
+		t.Errorf("Expected old key to be empty, got %d", len(results))
+	}
+	if results, _ := s.Lookup("by_total", []any{30}); len(results) != 1 {
+		t.Errorf("Expected document under new key, got %d", len(results))
+	}
+
+	if results, _ := s.LookupRange("by_total", []any{0}, []any{100}); len(results) != 2 {
+		t.Errorf("Expected range scan over derived keys, got %d", len(results))
+	}
+
+	_ = s.Delete(id)
+	if results, _ := s.Lookup("by_total", []any{30}); len(results) != 0 {
+		t.Errorf("Expected deleted document to leave the index, got %d", len(results))
+	}
+
+	if err := s.CreateFunctionalIndex("nil_fn", nil); err != ErrEmptyIndex {
+		t.Errorf("Expected ErrEmptyIndex, got %v", err)
+	}
+}
+
+// TestIndexMinMax tests fetching the documents at either end of an index.
+func TestIndexMinMax(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_price", []string{"price"})
+
+	if _, err := s.IndexMin("by_price"); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound on empty index, got %v", err)
+	}
+
+	for _, price := range []any{25, 1200, 3.5, 75} {
+		_, _ = s.Insert(map[string]any{"price": price})
+	}
+	_, _ = s.Insert(map[string]any{"name": "unpriced"})
+
+	cheapest, err := s.IndexMin("by_price")
+	if err != nil {
+		t.Fatalf("IndexMin failed: %v", err)
+	}
+	if cheapest.Data["price"] != 3.5 {
+		t.Errorf("Expected min price 3.5, got %v", cheapest.Data["price"])
+	}
+
+	priciest, err := s.IndexMax("by_price")
+	if err != nil {
+		t.Fatalf("IndexMax failed: %v", err)
+	}
+	if priciest.Data["price"] != 1200 {
+		t.Errorf("Expected max price 1200, got %v", priciest.Data["price"])
+	}
+
+	if _, err := s.IndexMax("missing"); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestDistinctValues tests listing the distinct keys of an index.
+func TestDistinctValues(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city_age", []string{"city", "age"})
+
+	_, _ = s.Insert(map[string]any{"city": "NYC", "age": 30})
+	_, _ = s.Insert(map[string]any{"city": "LA", "age": 25})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "age": 30})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "age": 20})
+	_, _ = s.Insert(map[string]any{"city": "SF"}) // Not indexed: missing age
+
+	values, err := s.DistinctValues("by_city_age")
+	if err != nil {
+		t.Fatalf("DistinctValues failed: %v", err)
+	}
+
+	expected := [][]any{{"LA", 25}, {"NYC", 20}, {"NYC", 30}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	if _, err := s.DistinctValues("missing"); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestDocumentTimestamps tests that creation and modification times are
+// tracked on insert and update and reported by every read path.
+func TestDocumentTimestamps(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_kind", []string{"kind"})
+
+	before := time.Now()
+	id, _ := s.Insert(map[string]any{"kind": "note"})
+	after := time.Now()
+
+	doc, _ := s.Get(id)
+	if doc.CreatedAt.Before(before) || doc.CreatedAt.After(after) {
+		t.Errorf("CreatedAt %v outside insert window [%v, %v]", doc.CreatedAt, before, after)
+	}
+	if !doc.UpdatedAt.Equal(doc.CreatedAt) {
+		t.Errorf("Expected UpdatedAt to equal CreatedAt before any update, got %v vs %v", doc.UpdatedAt, doc.CreatedAt)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	_ = s.Update(id, map[string]any{"kind": "note", "body": "edited"})
+
+	updated, _ := s.Get(id)
+	if !updated.CreatedAt.Equal(doc.CreatedAt) {
+		t.Errorf("Update changed CreatedAt: %v -> %v", doc.CreatedAt, updated.CreatedAt)
+	}
+	if !updated.UpdatedAt.After(doc.UpdatedAt) {
+		t.Errorf("Expected UpdatedAt to advance, got %v -> %v", doc.UpdatedAt, updated.UpdatedAt)
+	}
+
+	results, _ := s.Lookup("by_kind", []any{"note"})
+	if len(results) != 1 || !results[0].UpdatedAt.Equal(updated.UpdatedAt) {
+		t.Errorf("Expected Lookup to report timestamps, got %+v", results)
+	}
+
+	stream := s.Stream(1)
+	streamed, err := stream.Next()
+	stream.Close()
+	if err != nil || !streamed.CreatedAt.Equal(doc.CreatedAt) {
+		t.Errorf("Expected Stream to report timestamps, got %+v (err=%v)", streamed, err)
+	}
+
+	clone, _ := s.Clone()
+	defer clone.Close()
+	if cloned, _ := clone.Get(id); !cloned.CreatedAt.Equal(doc.CreatedAt) || !cloned.UpdatedAt.Equal(updated.UpdatedAt) {
+		t.Errorf("Expected Clone to preserve timestamps, got %+v", cloned)
+	}
+}
+
+// TestImportWithConflict tests upsert-on-import with a resolver.
+func TestImportWithConflict(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_email", []string{"email"})
+
+	aliceID, _ := s.Insert(map[string]any{"email": "alice@example.com", "visits": 1})
+	bobID, _ := s.Insert(map[string]any{"email": "bob@example.com", "visits": 5})
+
+	incoming := []map[string]any{
+		{"email": "alice@example.com", "visits": 2}, // Merged
+		{"email": "bob@example.com", "visits": 1},   // Rejected by resolver
+		{"email": "carol@example.com", "visits": 1}, // New
+		{"email": "carol@example.com", "visits": 4}, // Duplicate within the batch
+		{"name": "no email"},                        // Not keyed, always inserted
+	}
+
+	resolve := func(existing, incoming map[string]any) (map[string]any, bool) {
+		sum := existing["visits"].(int) + incoming["visits"].(int)
+		if incoming["visits"].(int) < 2 {
+			return nil, false
+		}
+		existing["visits"] = sum
+		return existing, true
+	}
+
+	inserted, updated, err := s.ImportWithConflict(incoming, "by_email", resolve)
+	if err != nil {
+		t.Fatalf("ImportWithConflict failed: %v", err)
+	}
+	if inserted != 2 || updated != 2 {
+		t.Errorf("Expected 2 inserted and 2 updated, got %d and %d", inserted, updated)
+	}
+
+	if doc, _ := s.Get(aliceID); doc.Data["visits"] != 3 {
+		t.Errorf("Expected merged visits 3, got %v", doc.Data["visits"])
+	}
+	if doc, _ := s.Get(bobID); doc.Data["visits"] != 5 || doc.Version != 2 {
+		t.Errorf("Expected rejected merge to leave Bob untouched, got %+v", doc)
+	}
+
+	carol, _ := s.Lookup("by_email", []any{"carol@example.com"})
+	if len(carol) != 1 || carol[0].Data["visits"] != 5 {
+		t.Errorf("Expected one merged Carol with 5 visits, got %v", carol)
+	}
+	if s.Count() != 4 {
+		t.Errorf("Expected 4 documents, got %d", s.Count())
+	}
+
+	if _, _, err := s.ImportWithConflict(incoming, "missing", resolve); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+	if _, _, err := s.ImportWithConflict([]map[string]any{nil}, "by_email", resolve); err != ErrInvalidDocument {
+		t.Errorf("Expected ErrInvalidDocument, got %v", err)
+	}
+}
+
+// TestUpdateBatch tests applying many updates at once, including missing IDs.
+func TestUpdateBatch(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_status", []string{"status"})
+
+	id1, _ := s.Insert(map[string]any{"status": "pending"})
+	id2, _ := s.Insert(map[string]any{"status": "pending"})
+
+	applied, err := s.UpdateBatch(map[string]map[string]any{
+		id1:       {"status": "done"},
+		id2:       {"status": "done"},
+		"ghost-b": {"status": "done"},
+		"ghost-a": {"status": "done"},
+	})
+	if applied != 2 {
+		t.Errorf("Expected 2 updates applied, got %d", applied)
+	}
+	if !errors.Is(err, ErrDocumentNotFound) || !strings.HasSuffix(err.Error(), "ghost-a, ghost-b") {
+		t.Errorf("Expected missing IDs to be reported, got %v", err)
+	}
+
+	if results, _ := s.Lookup("by_status", []any{"done"}); len(results) != 2 {
+		t.Errorf("Expected 2 documents re-indexed as done, got %d", len(results))
+	}
+	if doc, _ := s.Get(id1); doc.Version != 3 && doc.Version != 4 {
+		t.Errorf("Expected a new version, got %d", doc.Version)
+	}
+
+	if _, err := s.UpdateBatch(map[string]map[string]any{id1: nil}); err != ErrInvalidDocument {
+		t.Errorf("Expected ErrInvalidDocument, got %v", err)
+	}
+
+	applied, err = s.UpdateBatch(map[string]map[string]any{id1: {"status": "archived"}})
+	if applied != 1 || err != nil {
+		t.Errorf("Expected clean single update, got %d, %v", applied, err)
+	}
+	// A failure other than a missing ID is returned as it is, not as missing
+	errRejected := errors.New("rejected")
+	checks := 0
+	s.SetValidator(func(doc map[string]any) error {
+		if doc["status"] != "rejected" {
+			return nil
+		}
+		if checks++; checks > 1 { // Passes the up-front check, fails when written
+			return errRejected
+		}
+		return nil
+	})
+	applied, err = s.UpdateBatch(map[string]map[string]any{
+		id1:     {"status": "rejected"},
+		id2:     {"status": "accepted"},
+		"ghost": {"status": "accepted"},
+	})
+	if applied != 1 {
+		t.Errorf("Expected the valid update to be applied, got %d", applied)
+	}
+	if !errors.Is(err, errRejected) || !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected both the validator error and the missing ID, got %v", err)
+	}
+	if strings.Contains(err.Error(), id1) {
+		t.Errorf("Expected the rejected document not to be reported missing, got %v", err)
+	}
+}
+
+// TestDeleteWhere tests deleting documents by predicate.
+func TestDeleteWhere(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_n", []string{"n"})
+
+	for i := range 10 {
+		_, _ = s.Insert(map[string]any{"n": i})
+	}
+
+	deleted, err := s.DeleteWhere(func(doc map[string]any) bool {
+		return doc["n"].(int)%3 == 0
+	})
+	if err != nil {
+		t.Fatalf("DeleteWhere failed: %v", err)
+	}
+	if deleted != 4 {
+		t.Errorf("Expected 4 deleted, got %d", deleted)
+	}
+	if s.Count() != 6 {
+		t.Errorf("Expected 6 remaining, got %d", s.Count())
+	}
+	if results, _ := s.LookupRange("by_n", []any{0}, []any{10}); len(results) != 6 {
+		t.Errorf("Expected deleted documents to leave the index, got %d", len(results))
+	}
+
+	s.Close()
+	if _, err := s.DeleteWhere(func(map[string]any) bool { return true }); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// TestUpdateWhere tests a bulk migration adding a default field.
+func TestUpdateWhere(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_role", []string{"role"})
+
+	legacy, _ := s.Insert(map[string]any{"name": "Alice"})
+	current, _ := s.Insert(map[string]any{"name": "Bob", "role": "admin"})
+
+	updated, err := s.UpdateWhere(
+		func(doc map[string]any) bool {
+			_, hasRole := doc["role"]
+			return !hasRole
+		},
+		func(doc map[string]any) map[string]any {
+			doc["role"] = "user"
+			return doc
+		},
+	)
+	if err != nil {
+		t.Fatalf("UpdateWhere failed: %v", err)
+	}
+	if updated != 1 {
+		t.Errorf("Expected 1 update, got %d", updated)
+	}
+
+	if doc, _ := s.Get(legacy); doc.Data["role"] != "user" || doc.Version != 3 {
+		t.Errorf("Expected migrated document at version 3, got %+v", doc)
+	}
+	if doc, _ := s.Get(current); doc.Version != 2 {
+		t.Errorf("Expected untouched document to keep its version, got %d", doc.Version)
+	}
+	if results, _ := s.Lookup("by_role", []any{"user"}); len(results) != 1 {
+		t.Errorf("Expected migrated document to be indexed, got %d", len(results))
+	}
+
+	// Returning nil skips the write
+	updated, _ = s.UpdateWhere(
+		func(map[string]any) bool { return true },
+		func(map[string]any) map[string]any { return nil },
+	)
+	if updated != 0 {
+		t.Errorf("Expected no updates, got %d", updated)
+	}
+}
+
+// TestTruncate tests clearing all documents while keeping indexes.
+func TestTruncate(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_n", []string{"n"})
+
+	var ids []string
+	for i := range 5 {
+		id, _ := s.Insert(map[string]any{"n": i})
+		ids = append(ids, id)
+	}
+	_ = s.SoftDelete(ids[0])
+
+	events, cancel := s.Watch(10)
+	defer cancel()
+
+	if err := s.Truncate(); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+
+	if s.Count() != 0 {
+		t.Errorf("Expected empty store, got %d", s.Count())
+	}
+	if _, err := s.Get(ids[1]); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+	if err := s.Restore(ids[0]); err != ErrDocumentNotFound {
+		t.Errorf("Expected soft-deleted documents to be truncated, got %v", err)
+	}
+	if !s.HasIndex("by_n") {
+		t.Fatal("Expected index definition to survive")
+	}
+	if stats, _ := s.IndexStats("by_n"); stats["entries"] != 0 {
+		t.Errorf("Expected empty index, got %v", stats)
+	}
+	if len(events) != 4 {
+		t.Errorf("Expected 4 delete events, got %d", len(events))
+	}
+
+	// The store keeps working and versions keep increasing: 5 inserts, then
+	// a version for the soft delete and for each of the 4 truncated documents
+	id, _ := s.Insert(map[string]any{"n": 42})
+	doc, _ := s.Get(id)
+	if doc.Version != 11 {
+		t.Errorf("Expected version counter to continue at 11, got %d", doc.Version)
+	}
+	if results, _ := s.Lookup("by_n", []any{42}); len(results) != 1 {
+		t.Errorf("Expected new document to be indexed, got %d", len(results))
+	}
+}
+
+// TestLookupRangeNullsLast tests nil bound components ordered after all values.
+func TestLookupRangeNullsLast(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city_age", []string{"city", "age"})
+
+	_, _ = s.Insert(map[string]any{"city": "LA", "age": 40})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "age": 20})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "age": 30})
+	_, _ = s.Insert(map[string]any{"city": "SF", "age": 10})
+
+	// By default nil sorts first, so the upper bound stops before any NYC key
+	results, _ := s.LookupRangeOpts("by_city_age", []any{"NYC"}, []any{"NYC", nil}, RangeOptions{})
+	if len(results) != 0 {
+		t.Errorf("Expected no results with nulls first, got %d", len(results))
+	}
+
+	results, err := s.LookupRangeOpts("by_city_age", []any{"NYC"}, []any{"NYC", nil}, RangeOptions{NullsLast: true})
+	if err != nil {
+		t.Fatalf("LookupRangeOpts failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected both NYC documents with nulls last, got %d", len(results))
+	}
+
+	// A nil in the minimum now starts after every value at that position
+	results, _ = s.LookupRangeOpts("by_city_age", []any{"LA", nil}, []any{"SF"}, RangeOptions{NullsLast: true})
+	if len(results) != 2 || results[0].Data["city"] != "NYC" {
+		t.Errorf("Expected the NYC documents only, got %v", results)
+	}
+
+	// Without nils the option doesn't change anything
+	plain, _ := s.LookupRange("by_city_age", []any{"LA"}, []any{"SF"})
+	withOpt, _ := s.LookupRangeOpts("by_city_age", []any{"LA"}, []any{"SF"}, RangeOptions{NullsLast: true})
+	if len(plain) != 3 || len(withOpt) != len(plain) {
+		t.Errorf("Expected identical results, got %d and %d", len(plain), len(withOpt))
+	}
+
+	if _, err := s.LookupRangeOpts("missing", nil, nil, RangeOptions{NullsLast: true}); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestCompareKeyToBound tests bound comparison with nulls first and last.
+func TestCompareKeyToBound(t *testing.T) {
+	key := []any{"NYC", 30}
+
+	if c := compareKeyToBound(key, []any{"NYC", nil}, false); c != 1 {
+		t.Errorf("Expected key after nil bound with nulls first, got %d", c)
+	}
+	if c := compareKeyToBound(key, []any{"NYC", nil}, true); c != -1 {
+		t.Errorf("Expected key before nil bound with nulls last, got %d", c)
+	}
+	if c := compareKeyToBound(key, []any{"NYC", 30}, true); c != 0 {
+		t.Errorf("Expected equal keys, got %d", c)
+	}
+	if c := compareKeyToBound(key, []any{"NYC"}, true); c != 1 {
+		t.Errorf("Expected longer key after its prefix, got %d", c)
+	}
+}
+
+// TestLookupRangeBounds tests all four combinations of bound inclusivity.
+func TestLookupRangeBounds(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_score", []string{"score"})
+	for i := 1; i <= 10; i++ {
+		_, _ = s.Insert(map[string]any{"score": i})
+	}
+	_, _ = s.Insert(map[string]any{"score": 3}) // Duplicate key on the minimum
+
+	tests := []struct {
+		minInclusive, maxInclusive bool
+		expected                   []int
+	}{
+		{true, true, []int{3, 3, 4, 5, 6}},
+		{true, false, []int{3, 3, 4, 5}},
+		{false, true, []int{4, 5, 6}},
+		{false, false, []int{4, 5}},
+	}
+
+	for _, tt := range tests {
+		results, err := s.LookupRangeBounds("by_score", []any{3}, []any{6}, tt.minInclusive, tt.maxInclusive)
+		if err != nil {
+			t.Fatalf("LookupRangeBounds failed: %v", err)
+		}
+
+		var scores []int
+		for _, doc := range results {
+			scores = append(scores, doc.Data["score"].(int))
+		}
+		if !reflect.DeepEqual(scores, tt.expected) {
+			t.Errorf("min inclusive=%v, max inclusive=%v: expected %v, got %v",
+				tt.minInclusive, tt.maxInclusive, tt.expected, scores)
+		}
+	}
+
+	// Mixed numeric types compare by value, so 6.0 is the same bound as 6
+	results, _ := s.LookupRangeBounds("by_score", []any{5.0}, []any{6.0}, false, true)
+	if len(results) != 1 || results[0].Data["score"] != 6 {
+		t.Errorf("Expected only score 6, got %v", results)
+	}
+
+	if _, err := s.LookupRangeBounds("missing", nil, nil, true, true); err != ErrIndexNotFound {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestSetIDGenerator tests custom ID generation and collision handling.
+func TestSetIDGenerator(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	next := 0
+	store.SetIDGenerator(func() string {
+		next++
+		return fmt.Sprintf("%d", next)
+	})
+
+	id, err := store.Insert(map[string]any{"name": "Alice"})
+	if err != nil || id != "1" {
+		t.Fatalf("Expected generated ID 1, got %q (%v)", id, err)
+	}
+	ids, err := store.InsertBatch([]map[string]any{{"name": "Bob"}, {"name": "Carol"}})
+	if err != nil || !reflect.DeepEqual(ids, []string{"2", "3"}) {
+		t.Fatalf("Expected batch IDs [2 3], got %v (%v)", ids, err)
+	}
+	if id, err := store.InsertWithTTL(map[string]any{"name": "Dave"}, time.Hour); err != nil || id != "4" {
+		t.Fatalf("Expected TTL insert ID 4, got %q (%v)", id, err)
+	}
+
+	// A generator that repeats itself is reported rather than overwriting
+	store.SetIDGenerator(func() string { return "1" })
+	_, err = store.Insert(map[string]any{"name": "Eve"})
+	if !errors.Is(err, ErrIDExists) {
+		t.Errorf("Expected ErrIDExists for a taken ID, got %v", err)
+	}
+	if !errors.Is(err, ErrDocumentExists) || errors.Is(ErrDocumentExists, ErrIDExists) {
+		t.Error("Expected ErrIDExists to be a distinct error wrapping ErrDocumentExists")
+	}
+	store.SetIDGenerator(func() string { return "dup" })
+	if _, err := store.InsertBatch([]map[string]any{{"a": 1}, {"a": 2}}); !errors.Is(err, ErrIDExists) {
+		t.Errorf("Expected ErrIDExists for duplicate batch IDs, got %v", err)
+	}
+	if _, err := store.Get("dup"); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected rejected batch to write nothing, got %v", err)
+	}
+
+	store.SetIDGenerator(nil)
+	id, err = store.Insert(map[string]any{"name": "Frank"})
+	if err != nil || len(id) != 36 {
+		t.Errorf("Expected nil to restore UUID generation, got %q (%v)", id, err)
+	}
+}
+
+// TestInsertWithID tests inserting under caller-supplied IDs.
+func TestInsertWithID(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	_ = store.CreateIndex("by_name", []string{"name"})
+
+	if err := store.InsertWithID("user-1", map[string]any{"name": "Alice"}); err != nil {
+		t.Fatalf("InsertWithID failed: %v", err)
+	}
+	doc, err := store.Get("user-1")
+	if err != nil || doc.Data["name"] != "Alice" {
+		t.Fatalf("Expected to get user-1, got %v (%v)", doc, err)
+	}
+	if results, _ := store.Lookup("by_name", []any{"Alice"}); len(results) != 1 || results[0].ID != "user-1" {
+		t.Errorf("Expected user-1 to be indexed, got %v", results)
+	}
+
+	if err := store.InsertWithID("user-1", map[string]any{"name": "Bob"}); !errors.Is(err, ErrIDExists) {
+		t.Errorf("Expected ErrIDExists for a live ID, got %v", err)
+	}
+	_ = store.SoftDelete("user-1")
+	if err := store.InsertWithID("user-1", map[string]any{"name": "Bob"}); !errors.Is(err, ErrIDExists) {
+		t.Errorf("Expected ErrIDExists for a soft-deleted ID, got %v", err)
+	}
+
+	if err := store.InsertWithID("", map[string]any{"name": "Bob"}); !errors.Is(err, ErrInvalidDocument) {
+		t.Errorf("Expected ErrInvalidDocument for an empty ID, got %v", err)
+	}
+	if err := store.InsertWithID("user-2", nil); !errors.Is(err, ErrInvalidDocument) {
+		t.Errorf("Expected ErrInvalidDocument for a nil document, got %v", err)
+	}
+
+	store.Close()
+	if err := store.InsertWithID("user-3", map[string]any{}); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// TestStreamOrdered tests sorted streaming with missing sort fields placed last.
+func TestStreamOrdered(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	ids := make(map[int]string)
+	for _, score := range []int{3, 1, 4, 2} {
+		ids[score], _ = s.Insert(map[string]any{"score": score})
+	}
+	noScore, _ := s.Insert(map[string]any{"name": "unscored"})
+	_ = s.Delete(ids[4])
+	ids[0], _ = s.Insert(map[string]any{"score": 0.5}) // Reuses the freed slot
+
+	collect := func(ascending bool) []string {
+		stream := s.StreamOrdered(2, "score", ascending)
+		defer stream.Close()
+
+		var got []string
+		for {
+			doc, err := stream.Next()
+			if err == ErrStreamClosed {
+				return got
+			}
+			if err != nil {
+				t.Fatalf("Error reading from stream: %v", err)
+			}
+			got = append(got, doc.ID)
+		}
+	}
+
+	if got, want := collect(true), []string{ids[0], ids[1], ids[2], ids[3], noScore}; !slices.Equal(got, want) {
+		t.Errorf("Ascending: expected %v, got %v", want, got)
+	}
+	if got, want := collect(false), []string{ids[3], ids[2], ids[1], ids[0], noScore}; !slices.Equal(got, want) {
+		t.Errorf("Descending: expected %v, got %v", want, got)
+	}
+
+	s.Close()
+	if _, err := s.StreamOrdered(1, "score", true).Next(); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// TestCreateTypedIndex tests that a lenient typed index leaves out documents
+// with mismatched value kinds.
+func TestCreateTypedIndex(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	if err := store.CreateTypedIndex("bad", []string{"a", "b"}, []reflect.Kind{reflect.Int}); !errors.Is(err, ErrInvalidKinds) {
+		t.Errorf("Expected ErrInvalidKinds, got %v", err)
+	}
+	if err := store.CreateTypedIndex("empty", nil, nil); !errors.Is(err, ErrEmptyIndex) {
+		t.Errorf("Expected ErrEmptyIndex, got %v", err)
+	}
+
+	intID, _ := store.Insert(map[string]any{"age": 5})
+	if err := store.CreateTypedIndex("by_age", []string{"age"}, []reflect.Kind{reflect.Int}); err != nil {
+		t.Fatalf("CreateTypedIndex failed: %v", err)
+	}
+	stringID, err := store.Insert(map[string]any{"age": "5"})
+	if err != nil {
+		t.Fatalf("Expected lenient index to accept the write, got %v", err)
+	}
+
+	if values, _ := store.DistinctValues("by_age"); !reflect.DeepEqual(values, [][]any{{5}}) {
+		t.Errorf("Expected only the int document to be indexed, got %v", values)
+	}
+	if results, _ := store.Lookup("by_age", []any{5}); len(results) != 1 || results[0].ID != intID {
+		t.Errorf("Expected to find the int document, got %v", results)
+	}
+
+	// Fixing the value's type brings the document into the index
+	_ = store.Update(stringID, map[string]any{"age": 7})
+	if results, _ := store.Lookup("by_age", []any{7}); len(results) != 1 || results[0].ID != stringID {
+		t.Errorf("Expected updated document to be indexed, got %v", results)
+	}
+}
+
+// TestCreateStrictTypedIndex tests that a strict typed index rejects writes
+// with mismatched value kinds.
+func TestCreateStrictTypedIndex(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	badID, _ := store.Insert(map[string]any{"age": "5"})
+	if err := store.CreateStrictTypedIndex("by_age", []string{"age"}, []reflect.Kind{reflect.Int}); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("Expected existing mismatch to fail creation, got %v", err)
+	}
+	if store.HasIndex("by_age") {
+		t.Fatal("Expected failed index not to be registered")
+	}
+	_ = store.Delete(badID)
+
+	if err := store.CreateStrictTypedIndex("by_age", []string{"age"}, []reflect.Kind{reflect.Int}); err != nil {
+		t.Fatalf("CreateStrictTypedIndex failed: %v", err)
+	}
+
+	id, err := store.Insert(map[string]any{"age": 30})
+	if err != nil {
+		t.Fatalf("Expected matching insert to succeed, got %v", err)
+	}
+	original, _ := store.Get(id)
+	if _, err := store.Insert(map[string]any{"name": "no age"}); err != nil {
+		t.Errorf("Expected missing fields to be allowed, got %v", err)
+	}
+
+	before := store.Count()
+	if _, err := store.Insert(map[string]any{"age": "30"}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Insert: expected ErrTypeMismatch, got %v", err)
+	}
+	if _, err := store.InsertBatch([]map[string]any{{"age": 1}, {"age": 2.5}}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("InsertBatch: expected ErrTypeMismatch, got %v", err)
+	}
+	if err := store.InsertWithID("x", map[string]any{"age": true}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("InsertWithID: expected ErrTypeMismatch, got %v", err)
+	}
+	if store.Count() != before {
+		t.Errorf("Expected rejected inserts to write nothing, count %d -> %d", before, store.Count())
+	}
+
+	if err := store.Patch(id, map[string]any{"age": "31"}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Patch: expected ErrTypeMismatch, got %v", err)
+	}
+	if _, err := store.UpdateBatch(map[string]map[string]any{id: {"age": "31"}}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("UpdateBatch: expected ErrTypeMismatch, got %v", err)
+	}
+	if doc, _ := store.Get(id); doc.Data["age"] != 30 || doc.Version != original.Version {
+		t.Errorf("Expected rejected updates to leave the document unchanged, got %v", doc)
+	}
+}
+
+// TestLookupIDs tests ID-only exact and range lookups.
+func TestLookupIDs(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	_ = store.CreateIndex("by_age", []string{"age"})
+	ids := make(map[int][]string)
+	for i := range 6 {
+		id, _ := store.Insert(map[string]any{"age": i % 3})
+		ids[i%3] = append(ids[i%3], id)
+	}
+
+	got, err := store.LookupIDs("by_age", []any{1})
+	if err != nil {
+		t.Fatalf("LookupIDs failed: %v", err)
+	}
+	if want := slices.Sorted(slices.Values(ids[1])); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got, _ := store.LookupIDs("by_age", []any{9}); len(got) != 0 {
+		t.Errorf("Expected no IDs for a missing key, got %v", got)
+	}
+
+	got, err = store.LookupRangeIDs("by_age", []any{1}, []any{3})
+	if err != nil {
+		t.Fatalf("LookupRangeIDs failed: %v", err)
+	}
+	want := append(slices.Sorted(slices.Values(ids[1])), slices.Sorted(slices.Values(ids[2]))...)
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if _, err := store.LookupIDs("missing", []any{1}); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+	if _, err := store.LookupRangeIDs("missing", nil, nil); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestLookupCovered tests that covered lookups return index keys per document.
+func TestLookupCovered(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	_ = store.CreateIndex("by_city_age", []string{"city", "age"})
+	_, _ = store.Insert(map[string]any{"city": "LA", "age": 30, "name": "Alice"})
+	_, _ = store.Insert(map[string]any{"city": "LA", "age": 30, "name": "Bob"})
+	_, _ = store.Insert(map[string]any{"city": "LA", "age": 40, "name": "Carol"})
+	_, _ = store.Insert(map[string]any{"city": "NYC", "age": 25, "name": "Dave"})
+
+	keys, err := store.LookupCovered("by_city_age", []any{"LA", 30})
+	if err != nil {
+		t.Fatalf("LookupCovered failed: %v", err)
+	}
+	if want := [][]any{{"LA", 30}, {"LA", 30}}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected %v, got %v", want, keys)
+	}
+
+	// Returned keys are copies
+	keys[0][0] = "changed"
+	if again, _ := store.LookupCovered("by_city_age", []any{"LA", 30}); again[0][0] != "LA" {
+		t.Error("Expected modifying a returned key to leave the index intact")
+	}
+
+	keys, err = store.LookupRangeCovered("by_city_age", []any{"LA", 35}, []any{"NYC", 30})
+	if err != nil {
+		t.Fatalf("LookupRangeCovered failed: %v", err)
+	}
+	if want := [][]any{{"LA", 40}, {"NYC", 25}}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected %v, got %v", want, keys)
+	}
+
+	if keys, _ := store.LookupCovered("by_city_age", []any{"SF", 1}); len(keys) != 0 {
+		t.Errorf("Expected no keys for a missing tuple, got %v", keys)
+	}
+	if _, err := store.LookupCovered("missing", nil); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestIndexExcludesUnorderableValues tests that documents with slice or map
+// values in indexed fields are left out of the index.
+func TestIndexExcludesUnorderableValues(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	_ = store.CreateIndex("by_meta", []string{"meta"})
+	_ = store.CreateFunctionalIndex("by_tags", func(doc map[string]any) (any, bool) {
+		return doc["tags"], true
+	})
+
+	scalarID, _ := store.Insert(map[string]any{"meta": "plain", "tags": "one"})
+	mapID, _ := store.Insert(map[string]any{"meta": map[string]any{"a": 1}, "tags": []any{"x", "y"}})
+
+	if values, _ := store.DistinctValues("by_meta"); !reflect.DeepEqual(values, [][]any{{"plain"}}) {
+		t.Errorf("Expected only the scalar value to be indexed, got %v", values)
+	}
+	if values, _ := store.DistinctValues("by_tags"); !reflect.DeepEqual(values, [][]any{{"one"}}) {
+		t.Errorf("Expected only the scalar key to be indexed, got %v", values)
+	}
+	if results, _ := store.Lookup("by_meta", []any{map[string]any{"a": 1}}); len(results) != 0 {
+		t.Errorf("Expected map lookups to find nothing, got %v", results)
+	}
+
+	// Replacing the map with a scalar brings the document into the index
+	_ = store.Update(mapID, map[string]any{"meta": "fixed"})
+	ids, _ := store.LookupIDs("by_meta", []any{"fixed"})
+	if len(ids) != 1 || ids[0] != mapID {
+		t.Errorf("Expected updated document to be indexed, got %v", ids)
+	}
+	if ids, _ := store.LookupIDs("by_meta", []any{"plain"}); len(ids) != 1 || ids[0] != scalarID {
+		t.Errorf("Expected scalar document to stay indexed, got %v", ids)
+	}
+}
+
+// TestCompact tests that compaction removes every hole in the collection.
+func TestCompact(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_n", []string{"n"})
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i], _ = s.Insert(map[string]any{"n": i})
+	}
+	ttlID, _ := s.InsertWithTTL(map[string]any{"n": 100}, time.Hour)
+
+	for i, id := range ids {
+		if i%3 != 0 {
+			_ = s.Delete(id)
+		}
+	}
+	_ = s.SoftDelete(ids[3])
+
+	before := len(s.collection.documents)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	c := s.collection
+	if len(c.documents) != 35 || len(c.documents) >= before || len(c.freeSlots) != 0 {
+		t.Errorf("Expected 35 contiguous documents, got len=%d (was %d) with %d free slots", len(c.documents), before, len(c.freeSlots))
+	}
+
+	for i := 0; i < 100; i += 3 {
+		if i == 3 {
+			continue
+		}
+		doc, err := s.Get(ids[i])
+		if err != nil || doc.Data["n"] != i {
+			t.Errorf("Expected document %d to survive compaction, got %v (%v)", i, doc, err)
+		}
+		if results, _ := s.Lookup("by_n", []any{i}); len(results) != 1 || results[0].ID != ids[i] {
+			t.Errorf("Expected document %d to stay indexed, got %v", i, results)
+		}
+	}
+
+	if err := s.Restore(ids[3]); err != nil {
+		t.Errorf("Expected soft-deleted document to be restorable, got %v", err)
+	}
+	if doc, _ := s.Get(ids[3]); doc == nil || doc.Data["n"] != 3 {
+		t.Errorf("Expected restored document, got %v", doc)
+	}
+	if !s.handles[ttlID].handle.hasTTL() {
+		t.Error("Expected TTL to survive compaction")
+	}
+
+	// New inserts append after the compacted block
+	id, _ := s.Insert(map[string]any{"n": 999})
+	if doc, err := s.Get(id); err != nil || doc.Data["n"] != 999 {
+		t.Errorf("Expected insert after compaction to work, got %v (%v)", doc, err)
+	}
+}
+
+// TestSetValidator tests that the validator can reject writes.
+func TestSetValidator(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	errAgeRequired := errors.New("age is required")
+	store.SetValidator(func(doc map[string]any) error {
+		age, ok := doc["age"].(int)
+		if !ok {
+			return errAgeRequired
+		}
+		doc["age"] = age + 100 // Mutations must not reach the store
+		return nil
+	})
+
+	if _, err := store.Insert(map[string]any{"name": "Alice"}); !errors.Is(err, errAgeRequired) {
+		t.Errorf("Insert: expected validator error, got %v", err)
+	}
+	if store.Count() != 0 {
+		t.Errorf("Expected rejected insert to write nothing, got %d documents", store.Count())
+	}
+
+	id, err := store.Insert(map[string]any{"name": "Alice", "age": 30})
+	if err != nil {
+		t.Fatalf("Expected valid insert to succeed, got %v", err)
+	}
+	if doc, _ := store.Get(id); doc.Data["age"] != 30 {
+		t.Errorf("Expected validator to see a copy, got stored age %v", doc.Data["age"])
+	}
+
+	if err := store.Update(id, map[string]any{"name": "Alice"}); !errors.Is(err, errAgeRequired) {
+		t.Errorf("Update: expected validator error, got %v", err)
+	}
+	if err := store.UnsetFields(id, []string{"age"}); !errors.Is(err, errAgeRequired) {
+		t.Errorf("UnsetFields: expected validator error, got %v", err)
+	}
+	if _, err := store.InsertWithTTL(map[string]any{}, time.Hour); !errors.Is(err, errAgeRequired) {
+		t.Errorf("InsertWithTTL: expected validator error, got %v", err)
+	}
+	updated, err := store.UpdateWhere(
+		func(map[string]any) bool { return true },
+		func(doc map[string]any) map[string]any {
+			delete(doc, "age")
+			return doc
+		},
+	)
+	if !errors.Is(err, errAgeRequired) || updated != 0 {
+		t.Errorf("UpdateWhere: expected validator error and no updates, got %d, %v", updated, err)
+	}
+	if doc, _ := store.Get(id); doc.Data["age"] != 30 || doc.Version != 1 {
+		t.Errorf("Expected rejected updates to leave the document unchanged, got %v", doc)
+	}
+
+	store.SetValidator(nil)
+	if _, err := store.Insert(map[string]any{}); err != nil {
+		t.Errorf("Expected writes to succeed without a validator, got %v", err)
+	}
+}
+
+// TestSetMaxDocumentBytes tests that oversized documents are rejected.
+func TestSetMaxDocumentBytes(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	small := map[string]any{"name": "a"}
+	large := map[string]any{"blob": strings.Repeat("x", 1024)}
+
+	// No limit by default
+	if _, err := store.Insert(large); err != nil {
+		t.Fatalf("Expected no limit by default, got %v", err)
+	}
+
+	store.SetMaxDocumentBytes(512)
+	id, err := store.Insert(small)
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if _, err := store.Insert(large); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected ErrDocumentTooLarge on insert, got %v", err)
+	}
+	if err := store.Update(id, large); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected ErrDocumentTooLarge on update, got %v", err)
+	}
+	if err := store.Patch(id, large); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected ErrDocumentTooLarge on patch, got %v", err)
+	}
+	updated, err := store.UpdateWhere(
+		func(doc map[string]any) bool { return doc["name"] == "a" },
+		func(doc map[string]any) map[string]any {
+			doc["blob"] = large["blob"]
+			return doc
+		},
+	)
+	if !errors.Is(err, ErrDocumentTooLarge) || updated != 0 {
+		t.Errorf("Expected ErrDocumentTooLarge and no updates from UpdateWhere, got %d, %v", updated, err)
+	}
+	if doc, _ := store.Get(id); doc.Data["blob"] != nil {
+		t.Errorf("Expected the rejected writes to leave the document unchanged, got %v", doc.Data)
+	}
+	if store.Count() != 2 {
+		t.Errorf("Expected 2 documents, got %d", store.Count())
+	}
+
+	// A zero limit disables the check again
+	store.SetMaxDocumentBytes(0)
+	if err := store.Update(id, large); err != nil {
+		t.Errorf("Expected the limit to be disabled, got %v", err)
+	}
+}
+
+// TestLookupRangeOpen tests ranges that are unbounded on one or both sides.
+func TestLookupRangeOpen(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	_ = store.CreateIndex("by_score", []string{"score"})
+	for _, score := range []int{5, 10, 15, 20} {
+		_, _ = store.Insert(map[string]any{"score": score})
+	}
+	_, _ = store.Insert(map[string]any{"name": "unscored"})
+
+	scores := func(results []*DocumentResult) []any {
+		values := make([]any, len(results))
+		for i, result := range results {
+			values[i] = result.Data["score"]
+		}
+		return values
+	}
+
+	tests := []struct {
+		name     string
+		min, max []any
+		want     []any
+	}{
+		{"at least 10", []any{10}, nil, []any{10, 15, 20}},
+		{"below 15", nil, []any{15}, []any{5, 10}},
+		{"everything", nil, nil, []any{5, 10, 15, 20}},
+		{"empty slices are open", []any{}, []any{}, []any{5, 10, 15, 20}},
+		{"bounded", []any{10}, []any{20}, []any{10, 15}},
+	}
+	for _, tt := range tests {
+		results, err := store.LookupRangeOpen("by_score", tt.min, tt.max)
+		if err != nil {
+			t.Fatalf("%s: LookupRangeOpen failed: %v", tt.name, err)
+		}
+		if got := scores(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// LookupRange treats a nil maximum as the smallest key, matching nothing
+	if results, _ := store.LookupRange("by_score", []any{10}, nil); len(results) != 0 {
+		t.Errorf("Expected LookupRange with a nil maximum to match nothing, got %v", scores(results))
+	}
+
+	if _, err := store.LookupRangeOpen("missing", nil, nil); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestVersion tests the global version counter accessor.
+func TestVersion(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	if v := store.Version(); v != 0 {
+		t.Errorf("Expected version 0 for an empty store, got %d", v)
+	}
+
+	id, _ := store.Insert(map[string]any{"n": 1})
+	afterInsert := store.Version()
+	if doc, _ := store.Get(id); doc.Version != afterInsert {
+		t.Errorf("Expected version %d to match the inserted document's, got %d", afterInsert, doc.Version)
+	}
+
+	_ = store.Update(id, map[string]any{"n": 2})
+	if v := store.Version(); v <= afterInsert {
+		t.Errorf("Expected version to advance past %d after update, got %d", afterInsert, v)
+	}
+
+	checkpoint := store.Version()
+	_, _ = store.Get(id)
+	_, _ = store.Lookup("missing", nil)
+	if v := store.Version(); v != checkpoint {
+		t.Errorf("Expected reads to leave the version at %d, got %d", checkpoint, v)
+	}
+}
+
+// TestDeleteIfVersion tests version-conditional deletes.
+func TestDeleteIfVersion(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	_ = store.CreateIndex("by_n", []string{"n"})
+	id, _ := store.Insert(map[string]any{"n": 1})
+	read, _ := store.Get(id)
+
+	_ = store.Update(id, map[string]any{"n": 2})
+	if err := store.DeleteIfVersion(id, read.Version); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict for a stale version, got %v", err)
+	}
+	if _, err := store.Get(id); err != nil {
+		t.Errorf("Expected document to survive a conflicting delete, got %v", err)
+	}
+
+	current, _ := store.Get(id)
+	if err := store.DeleteIfVersion(id, current.Version); err != nil {
+		t.Fatalf("Expected delete with the current version to succeed, got %v", err)
+	}
+	if _, err := store.Get(id); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected document to be deleted, got %v", err)
+	}
+	if results, _ := store.Lookup("by_n", []any{2}); len(results) != 0 {
+		t.Errorf("Expected document to leave the index, got %v", results)
+	}
+
+	if err := store.DeleteIfVersion(id, current.Version); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound for a deleted document, got %v", err)
+	}
+}
+
+// TestCountRange tests that range counts match the documents a lookup returns.
+func TestCountRange(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	_ = store.CreateIndex("by_score", []string{"score"})
+	for i := range 30 {
+		_, _ = store.Insert(map[string]any{"score": i % 25})
+	}
+
+	count, err := store.CountRange("by_score", []any{10}, []any{20})
+	if err != nil {
+		t.Fatalf("CountRange failed: %v", err)
+	}
+	docs, _ := store.LookupRange("by_score", []any{10}, []any{20})
+	if count != 10 || count != len(docs) {
+		t.Errorf("Expected 10 documents matching LookupRange's %d, got %d", len(docs), count)
+	}
+
+	// Keys shared by several documents count every document
+	if count, _ := store.CountRange("by_score", []any{0}, []any{5}); count != 10 {
+		t.Errorf("Expected 10 documents, got %d", count)
+	}
+	if count, _ := store.CountRange("by_score", []any{100}, []any{200}); count != 0 {
+		t.Errorf("Expected no documents, got %d", count)
+	}
+
+	if _, err := store.CountRange("missing", nil, nil); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+	store.Close()
+	if _, err := store.CountRange("by_score", nil, nil); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// TestCreateArrayIndex tests that documents are found by any element of an
+// array field and that writes keep every element entry in step.
+func TestCreateArrayIndex(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	tagged := func(name string) []string {
+		docs, err := store.Lookup("by_tag", []any{name})
+		if err != nil {
+			t.Fatalf("Lookup failed: %v", err)
+		}
+		var ids []string
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	first, _ := store.Insert(map[string]any{"tags": []any{"a", "b", "a"}})
+	if err := store.CreateArrayIndex("by_tag", "tags"); err != nil {
+		t.Fatalf("CreateArrayIndex failed: %v", err)
+	}
+	second, _ := store.Insert(map[string]any{"tags": []string{"b", "c"}})
+	scalar, _ := store.Insert(map[string]any{"tags": "c"})
+	_, _ = store.Insert(map[string]any{"tags": []any{}})
+	_, _ = store.Insert(map[string]any{"other": true})
+
+	if got := tagged("a"); !slices.Equal(got, []string{first}) {
+		t.Errorf("Expected only the first document for a, got %v", got)
+	}
+	if got, want := tagged("b"), slices.Sorted(slices.Values([]string{first, second})); !slices.Equal(got, want) {
+		t.Errorf("Expected %v for b, got %v", want, got)
+	}
+	if got, want := tagged("c"), slices.Sorted(slices.Values([]string{second, scalar})); !slices.Equal(got, want) {
+		t.Errorf("Expected %v for c, got %v", want, got)
+	}
+
+	// A range matching several elements returns and counts a document once
+	docs, _ := store.LookupRange("by_tag", []any{"a"}, []any{"z"})
+	if len(docs) != 3 {
+		t.Errorf("Expected 3 distinct documents in the range, got %d", len(docs))
+	}
+	if count, _ := store.CountRange("by_tag", []any{"a"}, []any{"z"}); count != 3 {
+		t.Errorf("Expected a range count of 3, got %d", count)
+	}
+
+	// Updates move every element entry; deletes remove them all
+	_ = store.Update(first, map[string]any{"tags": []any{"d"}})
+	if got := tagged("a"); len(got) != 0 {
+		t.Errorf("Expected no documents for a after the update, got %v", got)
+	}
+	if got := tagged("d"); !slices.Equal(got, []string{first}) {
+		t.Errorf("Expected the first document for d, got %v", got)
+	}
+	_ = store.Delete(second)
+	if got := tagged("b"); len(got) != 0 {
+		t.Errorf("Expected no documents for b after the delete, got %v", got)
+	}
+	if got := tagged("c"); !slices.Equal(got, []string{scalar}) {
+		t.Errorf("Expected only the scalar document for c, got %v", got)
+	}
+
+	// The index survives Encode and Decode
+	var buf bytes.Buffer
+	_ = store.Encode(&buf)
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	defer decoded.Close()
+	if docs, err := decoded.Lookup("by_tag", []any{"d"}); err != nil || len(docs) != 1 {
+		t.Errorf("Expected the decoded array index to find d, got %v (%v)", docs, err)
+	}
+
+	if err := store.CreateArrayIndex("empty", ""); !errors.Is(err, ErrEmptyIndex) {
+		t.Errorf("Expected ErrEmptyIndex, got %v", err)
+	}
+}
+
+// TestCreateTextIndex tests word search over a tokenized string field.
+func TestCreateTextIndex(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	search := func(term string) []any {
+		docs, err := store.Search("by_word", term)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		var names []any
+		for _, doc := range docs {
+			names = append(names, doc.Data["name"])
+		}
+		slices.SortFunc(names, func(a, b any) int { return strings.Compare(a.(string), b.(string)) })
+		return names
+	}
+
+	if err := store.CreateTextIndex("by_word", "body", nil); err != nil {
+		t.Fatalf("CreateTextIndex failed: %v", err)
+	}
+	fox, _ := store.Insert(map[string]any{"name": "fox", "body": "The quick brown Fox"})
+	_, _ = store.Insert(map[string]any{"name": "dog", "body": "the lazy dog, the end"})
+	_, _ = store.Insert(map[string]any{"name": "number", "body": 42})
+
+	if got := search("the"); !reflect.DeepEqual(got, []any{"dog", "fox"}) {
+		t.Errorf("Expected both documents for the, got %v", got)
+	}
+	if got := search("FOX"); !reflect.DeepEqual(got, []any{"fox"}) {
+		t.Errorf("Expected case-insensitive matching, got %v", got)
+	}
+	if got := search("quick fox"); !reflect.DeepEqual(got, []any{"fox"}) {
+		t.Errorf("Expected documents with every word, got %v", got)
+	}
+	if got := search("quick dog"); len(got) != 0 {
+		t.Errorf("Expected no document with both words, got %v", got)
+	}
+	if got := search("  "); len(got) != 0 {
+		t.Errorf("Expected no results for an empty term, got %v", got)
+	}
+
+	// Updates and deletes re-tokenize the field
+	_ = store.Update(fox, map[string]any{"name": "fox", "body": "a slow fox"})
+	if got := search("quick"); len(got) != 0 {
+		t.Errorf("Expected quick to be gone after the update, got %v", got)
+	}
+	if got := search("slow"); !reflect.DeepEqual(got, []any{"fox"}) {
+		t.Errorf("Expected slow after the update, got %v", got)
+	}
+	_ = store.Delete(fox)
+	if got := search("fox"); len(got) != 0 {
+		t.Errorf("Expected fox to be gone after the delete, got %v", got)
+	}
+
+	// A custom tokenizer decides what a term is
+	byComma := func(text string) []string { return strings.Split(text, ",") }
+	_ = store.CreateTextIndex("by_part", "body", byComma)
+	if docs, _ := store.Search("by_part", " the end"); len(docs) != 1 {
+		t.Errorf("Expected the custom tokenizer to match one document, got %d", len(docs))
+	}
+
+	if _, err := store.Search("missing", "fox"); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestCreateIndexWithComparator tests an index ordered by a custom comparator.
+func TestCreateIndexWithComparator(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	// Orders string-encoded numbers numerically
+	numeric := func(a, b []any) int {
+		for i := range min(len(a), len(b)) {
+			x, _ := strconv.Atoi(fmt.Sprint(a[i]))
+			y, _ := strconv.Atoi(fmt.Sprint(b[i]))
+			if c := cmp.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(len(a), len(b))
+	}
+
+	if err := store.CreateIndexWithComparator("by_rank", []string{"rank"}, numeric); err != nil {
+		t.Fatalf("CreateIndexWithComparator failed: %v", err)
+	}
+	for _, rank := range []string{"10", "9", "100", "2", "09"} {
+		_, _ = store.Insert(map[string]any{"rank": rank})
+	}
+
+	ranks := func(docs []*DocumentResult) []any {
+		var got []any
+		for _, doc := range docs {
+			got = append(got, doc.Data["rank"])
+		}
+		return got
+	}
+
+	cursor, _ := store.ReadIndex("by_rank")
+	var ordered []any
+	for {
+		doc, _, _ := cursor.Next()
+		if doc == nil {
+			break
+		}
+		ordered = append(ordered, (*doc)["rank"])
+	}
+	cursor.Close()
+	if len(ordered) != 5 || ordered[0] != "2" || ordered[3] != "10" || ordered[4] != "100" {
+		t.Errorf("Expected numeric order, got %v", ordered)
+	}
+
+	// Equal keys under the comparator share an entry, and lookups follow it
+	if docs, _ := store.Lookup("by_rank", []any{"9"}); len(docs) != 2 {
+		t.Errorf("Expected 9 and 09 to match, got %v", ranks(docs))
+	}
+	docs, _ := store.LookupRange("by_rank", []any{"3"}, []any{"50"})
+	if got := ranks(docs); len(got) != 3 {
+		t.Errorf("Expected 9, 09 and 10 in [3, 50), got %v", got)
+	}
+	if docs, _ := store.LookupRangeBounds("by_rank", []any{"9"}, []any{"100"}, false, true); !reflect.DeepEqual(ranks(docs), []any{"10", "100"}) {
+		t.Errorf("Expected (9, 100] to hold 10 and 100, got %v", ranks(docs))
+	}
+
+	// Updates that keep an equal key stay findable
+	docs, _ = store.Lookup("by_rank", []any{"2"})
+	_ = store.Update(docs[0].ID, map[string]any{"rank": "02"})
+	if err := store.Delete(docs[0].ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if count, _ := store.CountRange("by_rank", []any{"0"}, []any{"1000"}); count != 4 {
+		t.Errorf("Expected 4 indexed documents after the delete, got %d", count)
+	}
+
+	if err := store.CreateIndexWithComparator("no_cmp", []string{"rank"}, nil); !errors.Is(err, ErrNilComparator) {
+		t.Errorf("Expected ErrNilComparator without a comparator, got %v", err)
+	}
+	if err := store.CreateIndexWithComparator("no_fields", nil, numeric); !errors.Is(err, ErrEmptyIndex) {
+		t.Errorf("Expected ErrEmptyIndex without fields, got %v", err)
+	}
+}
+
+// TestCloneCtx tests that clones report what they copied and can be cancelled.
+func TestCloneCtx(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_n", []string{"n"})
+	_ = s.CreateIndex("by_group", []string{"group"})
+	for i := range 10 {
+		_, _ = s.Insert(map[string]any{"n": i, "group": i % 2})
+	}
+
+	clone, result, err := s.CloneCtx(context.Background())
+	if err != nil {
+		t.Fatalf("CloneCtx failed: %v", err)
+	}
+	defer clone.Close()
+
+	if result != (CloneResult{Documents: 10, Indexes: 2}) {
+		t.Errorf("Expected 10 documents and 2 indexes, got %+v", result)
+	}
+	if docs, _ := clone.Lookup("by_group", []any{1}); len(docs) != 5 {
+		t.Errorf("Expected the cloned index to hold 5 documents, got %d", len(docs))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if clone, result, err := s.CloneCtx(ctx); !errors.Is(err, context.Canceled) || clone != nil || result != (CloneResult{}) {
+		t.Errorf("Expected a cancelled clone to copy nothing, got %v, %+v, %v", clone, result, err)
+	}
+
+	s.Close()
+	if _, _, err := s.CloneCtx(context.Background()); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// TestLookupOne tests single-match lookups.
+func TestLookupOne(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	_ = store.CreateIndex("by_email", []string{"email"})
+	id, _ := store.Insert(map[string]any{"email": "a@example.com"})
+	_, _ = store.Insert(map[string]any{"email": "b@example.com"})
+	_, _ = store.Insert(map[string]any{"email": "b@example.com"})
+
+	doc, err := store.LookupOne("by_email", []any{"a@example.com"})
+	if err != nil || doc.ID != id {
+		t.Errorf("Expected document %s, got %v (%v)", id, doc, err)
+	}
+	if _, err := store.LookupOne("by_email", []any{"c@example.com"}); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+	if _, err := store.LookupOne("by_email", []any{"b@example.com"}); !errors.Is(err, ErrMultipleMatches) {
+		t.Errorf("Expected ErrMultipleMatches, got %v", err)
+	}
+	if _, err := store.LookupOne("missing", []any{"a@example.com"}); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestStreamLookup tests streaming the documents under a single index key.
+func TestStreamLookup(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_group", []string{"group"})
+	var hot []string
+	for i := range 50 {
+		id, _ := s.Insert(map[string]any{"group": i % 5})
+		if i%5 == 0 {
+			hot = append(hot, id)
+		}
+	}
+	_ = s.Delete(hot[0])
+
+	stream, err := s.StreamLookup("by_group", []any{0}, 2)
+	if err != nil {
+		t.Fatalf("StreamLookup failed: %v", err)
+	}
+
+	var got []string
+	for {
+		doc, err := stream.Next()
+		if err == ErrStreamClosed {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading from stream: %v", err)
+		}
+		got = append(got, doc.ID)
+	}
+	if want := slices.Sorted(slices.Values(hot[1:])); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// Closing the stream stops delivery
+	stream, _ = s.StreamLookup("by_group", []any{1}, 0)
+	if _, err := stream.Next(); err != nil {
+		t.Fatalf("First Next failed: %v", err)
+	}
+	stream.Close()
+
+	received := 0
+	for {
+		if _, err := stream.Next(); err != nil {
+			break
+		}
+		received++
+	}
+	if received > 1 {
+		t.Errorf("Expected a closed stream to stop, received %d more documents", received)
+	}
+
+	if _, err := s.StreamLookup("missing", []any{0}, 0); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+// TestInsertIfAbsent tests idempotent inserts keyed by an index.
+func TestInsertIfAbsent(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	_ = store.CreateIndex("by_key", []string{"key"})
+
+	id, inserted, err := store.InsertIfAbsent("by_key", []any{"k1"}, map[string]any{"key": "k1", "n": 1})
+	if err != nil || !inserted {
+		t.Fatalf("Expected the first insert to succeed, got %v (%v)", inserted, err)
+	}
+
+	again, inserted, err := store.InsertIfAbsent("by_key", []any{"k1"}, map[string]any{"key": "k1", "n": 2})
+	if err != nil || inserted || again != id {
+		t.Errorf("Expected the existing ID %s, got %s, %v (%v)", id, again, inserted, err)
+	}
+	if doc, _ := store.Get(id); doc.Data["n"] != 1 {
+		t.Errorf("Expected the existing document to be left unchanged, got %v", doc.Data)
+	}
+
+	// Concurrent calls with the same key insert one document
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	insertedCount := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, inserted, _ := store.InsertIfAbsent("by_key", []any{"k2"}, map[string]any{"key": "k2"}); inserted {
+				mu.Lock()
+				insertedCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if docs, _ := store.Lookup("by_key", []any{"k2"}); insertedCount != 1 || len(docs) != 1 {
+		t.Errorf("Expected exactly one k2 document, inserted %d and found %d", insertedCount, len(docs))
+	}
+
+	// Expired documents don't count as present
+	_, _ = store.InsertWithTTL(map[string]any{"key": "k3"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, inserted, _ := store.InsertIfAbsent("by_key", []any{"k3"}, map[string]any{"key": "k3"}); !inserted {
+		t.Error("Expected an expired match to allow the insert")
+	}
+
+	if _, _, err := store.InsertIfAbsent("missing", []any{"k1"}, map[string]any{}); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+	if _, _, err := store.InsertIfAbsent("by_key", []any{"k4"}, nil); !errors.Is(err, ErrInvalidDocument) {
+		t.Errorf("Expected ErrInvalidDocument, got %v", err)
+	}
+}
+
+// TestEdge_NilStore checks that methods called on a nil *Store report
+// ErrStoreNil rather than panicking.
+func TestEdge_NilStore(t *testing.T) {
+	var s *Store
+
+	if _, err := s.Insert(map[string]any{"name": "Alice"}); !errors.Is(err, ErrStoreNil) {
+		t.Fatalf("Insert on nil store: expected ErrStoreNil, got %v", err)
+	}
+	if _, err := s.Get("missing"); !errors.Is(err, ErrStoreNil) {
+		t.Errorf("Get on nil store: expected ErrStoreNil, got %v", err)
+	}
+	if err := s.CreateIndex("by_name", []string{"name"}); !errors.Is(err, ErrStoreNil) {
+		t.Errorf("CreateIndex on nil store: expected ErrStoreNil, got %v", err)
+	}
+	if _, err := s.Stream(1).Next(); !errors.Is(err, ErrStoreNil) {
+		t.Errorf("Stream on nil store: expected ErrStoreNil, got %v", err)
+	}
+	if n := s.Count(); n != 0 {
+		t.Errorf("Count on nil store: expected 0, got %d", n)
+	}
+
+	tx := s.Begin()
+	if _, err := tx.Insert(map[string]any{"name": "Alice"}); !errors.Is(err, ErrStoreNil) {
+		t.Errorf("Txn insert on nil store: expected ErrStoreNil, got %v", err)
+	}
+	if _, err := tx.Get("missing"); !errors.Is(err, ErrStoreNil) {
+		t.Errorf("Txn get on nil store: expected ErrStoreNil, got %v", err)
+	}
+	if _, err := tx.CountByIndexRange("by_name", nil, nil); !errors.Is(err, ErrStoreNil) {
+		t.Errorf("Txn count on nil store: expected ErrStoreNil, got %v", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrStoreNil) {
+		t.Errorf("Txn commit on nil store: expected ErrStoreNil, got %v", err)
+	}
+	s.Close() // Must not panic
+}
@@ -0,0 +1,18 @@
+package gostore
+
+// Storer is the core document store API implemented by *Store. Code that
+// depends on Storer instead of *Store can substitute a fake in its tests.
+type Storer interface {
+	Insert(doc map[string]any) (string, error)
+	Get(docID string) (*DocumentResult, error)
+	Update(docID string, doc map[string]any) error
+	Delete(docID string) error
+	Lookup(indexName string, values []any) ([]*DocumentResult, error)
+	LookupRange(indexName string, minValues, maxValues []any) ([]*DocumentResult, error)
+	Stream(bufferSize int) *DocumentStream
+	CreateIndex(indexName string, fields []string) error
+	DropIndex(indexName string) error
+	Close()
+}
+
+var _ Storer = (*Store)(nil)
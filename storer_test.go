@@ -0,0 +1,46 @@
+package gostore
+
+import "testing"
+
+// fakeStorer overrides Get on top of a real store, the way a caller's test
+// double would.
+type fakeStorer struct {
+	Storer
+	gets int
+}
+
+func (f *fakeStorer) Get(docID string) (*DocumentResult, error) {
+	f.gets++
+	return f.Storer.Get(docID)
+}
+
+// TestStorer tests that code written against Storer accepts a store or a fake.
+func TestStorer(t *testing.T) {
+	rename := func(st Storer, docID, name string) error {
+		doc, err := st.Get(docID)
+		if err != nil {
+			return err
+		}
+		doc.Data["name"] = name
+		return st.Update(docID, doc.Data)
+	}
+
+	s := NewStore()
+	defer s.Close()
+	id, _ := s.Insert(map[string]any{"name": "a"})
+
+	if err := rename(s, id, "b"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+
+	fake := &fakeStorer{Storer: s}
+	if err := rename(fake, id, "c"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	if fake.gets != 1 {
+		t.Errorf("Expected the fake's Get to be called once, got %d", fake.gets)
+	}
+	if doc, _ := s.Get(id); doc.Data["name"] != "c" {
+		t.Errorf("Expected name c, got %v", doc.Data["name"])
+	}
+}
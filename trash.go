@@ -0,0 +1,156 @@
+package gostore
+
+import "sync/atomic"
+
+// setTrashed moves a document into or out of the trash, reporting whether its
+// state changed. Trashed documents keep their data but are invisible to Get,
+// Update and GetAllValid and are not counted as live.
+func (c *Collection) setTrashed(index int, trashed bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if index < 0 || index >= len(c.documents) {
+		return false
+	}
+
+	doc := c.documents[index]
+	if doc == nil || doc.deleted || doc.trashed == trashed {
+		return false
+	}
+
+	doc.trashed = trashed
+	if trashed {
+		c.count.Add(-1)
+	} else {
+		c.count.Add(1)
+	}
+	return true
+}
+
+// setVersion assigns a document a new version without touching its data or
+// timestamps.
+func (c *Collection) setVersion(index int, version uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if index < 0 || index >= len(c.documents) {
+		return false
+	}
+
+	doc := c.documents[index]
+	if doc == nil || doc.deleted {
+		return false
+	}
+
+	doc.version = version
+	return true
+}
+
+// SoftDelete removes a document from the indexes and from every query, like
+// Delete, but keeps its data so it can be brought back with Restore. The slot
+// is only reclaimed by PurgeDeleted.
+func (s *Store) SoftDelete(docID string) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.handles[docID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+
+	doc, exists := s.collection.Get(entry.handle.index)
+	if !exists {
+		return ErrDocumentDeleted
+	}
+
+	for _, indexName := range entry.indexes {
+		if idx, exists := s.indexes[indexName]; exists {
+			idx.deleteDocument(docID, doc.data)
+		}
+	}
+
+	s.collection.setTrashed(entry.handle.index, true)
+	s.removeHandle(docID)
+	s.trackRemove(docID)
+	s.recordTombstone(docID)
+	s.trash[docID] = entry.handle
+	s.broadcast(ChangeEvent{Type: ChangeDelete, ID: docID, Version: doc.version})
+
+	return nil
+}
+
+// Restore brings a soft-deleted document back with its data intact and
+// re-adds it to the indexes. The document takes a new version, newer than the
+// soft delete's, so StreamChanges sends it again to replicas that have
+// already applied the delete. It fails with ErrDocumentNotFound if the
+// document is not in the trash.
+func (s *Store) Restore(docID string) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	handle, exists := s.trash[docID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+
+	s.collection.setTrashed(handle.index, false)
+
+	// Validation added since the soft delete may reject it
+	if doc, exists := s.collection.Get(handle.index); exists {
+		if err := s.validateDocument(doc.data); err != nil {
+			s.collection.setTrashed(handle.index, true)
+			return err
+		}
+	}
+	delete(s.trash, docID)
+	s.collection.setVersion(handle.index, atomic.AddUint64(&s.version, 1))
+
+	entry := HandleEntry{
+		handle:  handle,
+		indexes: make([]string, 0, len(s.indexes)),
+	}
+	for idxName, idx := range s.indexes {
+		if idx.insertDocument(handle) {
+			entry.indexes = append(entry.indexes, idxName)
+		}
+	}
+	s.setHandle(docID, entry)
+	s.trackInsert(docID)
+
+	if doc, exists := s.collection.Get(handle.index); exists {
+		s.broadcast(ChangeEvent{Type: ChangeInsert, ID: docID, Version: doc.version})
+	}
+
+	s.evictOverflow()
+	return nil
+}
+
+// PurgeDeleted permanently removes every soft-deleted document, freeing its
+// slot, and returns how many were purged.
+func (s *Store) PurgeDeleted() int {
+	if s.ensureOpen() != nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for docID, handle := range s.trash {
+		if s.collection.Delete(handle.index) {
+			purged++
+		}
+		delete(s.trash, docID)
+		delete(s.expiring, docID)
+	}
+
+	return purged
+}
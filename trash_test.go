@@ -0,0 +1,95 @@
+package gostore
+
+import (
+	"testing"
+)
+
+// TestSoftDeleteAndRestore tests that soft-deleted documents disappear from
+// queries and come back intact when restored.
+func TestSoftDeleteAndRestore(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city", []string{"city"})
+
+	id, _ := s.Insert(map[string]any{"city": "NYC", "name": "Alice"})
+	_, _ = s.Insert(map[string]any{"city": "NYC", "name": "Bob"})
+
+	if err := s.SoftDelete(id); err != nil {
+		t.Fatalf("SoftDelete failed: %v", err)
+	}
+
+	if _, err := s.Get(id); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound for soft-deleted document, got %v", err)
+	}
+	if results, _ := s.Lookup("by_city", []any{"NYC"}); len(results) != 1 {
+		t.Errorf("Expected soft-deleted document to leave the index, got %d", len(results))
+	}
+	if s.Count() != 1 {
+		t.Errorf("Expected count 1, got %d", s.Count())
+	}
+	if valid := s.collection.GetAllValid(); len(valid) != 1 {
+		t.Errorf("Expected soft-deleted document to be hidden from scans")
+	}
+	if err := s.Update(id, map[string]any{"city": "LA"}); err != ErrDocumentNotFound {
+		t.Errorf("Expected update of soft-deleted document to fail, got %v", err)
+	}
+	if err := s.SoftDelete(id); err != ErrDocumentNotFound {
+		t.Errorf("Expected second SoftDelete to fail, got %v", err)
+	}
+
+	if err := s.Restore(id); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	doc, err := s.Get(id)
+	if err != nil {
+		t.Fatalf("Expected restored document, got %v", err)
+	}
+	if doc.Data["name"] != "Alice" || doc.Version <= 1 {
+		t.Errorf("Expected original data with a new version, got %+v", doc)
+	}
+	if results, _ := s.Lookup("by_city", []any{"NYC"}); len(results) != 2 {
+		t.Errorf("Expected restored document to be re-indexed, got %d", len(results))
+	}
+	if s.Count() != 2 {
+		t.Errorf("Expected count 2, got %d", s.Count())
+	}
+
+	if err := s.Restore(id); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound restoring a live document, got %v", err)
+	}
+}
+
+// TestPurgeDeleted tests that purging reclaims soft-deleted slots for good.
+func TestPurgeDeleted(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	id1, _ := s.Insert(map[string]any{"n": 1})
+	id2, _ := s.Insert(map[string]any{"n": 2})
+	kept, _ := s.Insert(map[string]any{"n": 3})
+
+	_ = s.SoftDelete(id1)
+	_ = s.SoftDelete(id2)
+
+	if purged := s.PurgeDeleted(); purged != 2 {
+		t.Errorf("Expected 2 purged, got %d", purged)
+	}
+	if err := s.Restore(id1); err != ErrDocumentNotFound {
+		t.Errorf("Expected purged document to be unrestorable, got %v", err)
+	}
+	if s.Count() != 1 {
+		t.Errorf("Expected count 1, got %d", s.Count())
+	}
+	if len(s.collection.freeSlots) != 2 {
+		t.Errorf("Expected 2 reclaimed slots, got %d", len(s.collection.freeSlots))
+	}
+	if _, err := s.Get(kept); err != nil {
+		t.Errorf("Expected untouched document to survive purge, got %v", err)
+	}
+
+	if purged := s.PurgeDeleted(); purged != 0 {
+		t.Errorf("Expected empty trash, got %d purged", purged)
+	}
+}
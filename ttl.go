@@ -0,0 +1,245 @@
+package gostore
+
+import (
+	"slices"
+	"time"
+)
+
+// defaultSweepInterval is how often the background sweeper removes expired documents.
+const defaultSweepInterval = time.Second
+
+// setExpiry arms (or, with a zero time, disarms) the handle's TTL.
+func (h *DocumentHandle) setExpiry(at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expiresAt = at
+}
+
+// expiry returns the handle's expiry time, or the zero time if it has no TTL.
+func (h *DocumentHandle) expiry() time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.expiresAt
+}
+
+// hasTTL reports whether the handle is TTL-managed.
+func (h *DocumentHandle) hasTTL() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return !h.expiresAt.IsZero()
+}
+
+// expired reports whether the handle carries a TTL that has elapsed by now.
+func (h *DocumentHandle) expired(now time.Time) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return !h.expiresAt.IsZero() && !now.Before(h.expiresAt)
+}
+
+// liveHandle returns docID's handle unless the document is missing or its TTL
+// has elapsed by now, so reads hide expired documents the sweeper has not
+// removed yet. The caller must hold s.mu.
+func (s *Store) liveHandle(docID string, now time.Time) (*DocumentHandle, bool) {
+	entry, exists := s.handles[docID]
+	if !exists || entry.handle.expired(now) {
+		return nil, false
+	}
+	return entry.handle, true
+}
+
+// liveIDs drops the IDs of expired documents from docIDs in place, keeping
+// the order of the rest.
+func (s *Store) liveIDs(docIDs []string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.expiring) == 0 {
+		return docIDs
+	}
+
+	now := time.Now()
+	return slices.DeleteFunc(docIDs, func(docID string) bool {
+		_, live := s.liveHandle(docID, now)
+		return !live
+	})
+}
+
+// liveFilter returns a predicate reporting whether a document has not
+// expired, for index walks that never read documents, or nil when no document
+// carries a TTL. The predicate must only be called while s.mu is held, as the
+// caller must hold it to call liveFilter.
+func (s *Store) liveFilter() func(docID string) bool {
+	if len(s.expiring) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	return func(docID string) bool {
+		_, live := s.liveHandle(docID, now)
+		return live
+	}
+}
+
+// countExpired counts the documents whose TTL has elapsed by now but that the
+// sweeper has not removed yet. The caller must hold s.mu.
+func (s *Store) countExpired(now time.Time) int {
+	expired := 0
+	for docID := range s.expiring {
+		if entry, exists := s.handles[docID]; exists && entry.handle.expired(now) {
+			expired++
+		}
+	}
+	return expired
+}
+
+// countLive counts the documents in a range of index that have not expired.
+// It only visits the matching IDs when some document carries a TTL, and sums
+// the index entries otherwise. The caller must hold s.mu.
+func (s *Store) countLive(index *fieldIndex, minValues, maxValues []any) int {
+	if len(s.expiring) == 0 {
+		return index.countRange(minValues, maxValues)
+	}
+
+	count := 0
+	now := time.Now()
+	for _, docID := range index.lookupRange(minValues, maxValues) {
+		if _, live := s.liveHandle(docID, now); live {
+			count++
+		}
+	}
+	return count
+}
+
+// Refresh resets the expiry of a TTL-managed document to ttl from now.
+// The document's data and version are left untouched, so repeatedly refreshing
+// on access gives sliding-expiration semantics. Documents without a TTL return
+// ErrNoTTL, and documents that have already expired cannot be revived.
+func (s *Store) Refresh(docID string, ttl time.Duration) error {
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+
+	// Held for writing so the sweeper can't remove the document and Compact
+	// can't replace its handle between the check and the new expiry
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	handle, live := s.liveHandle(docID, now)
+	if !live {
+		return ErrDocumentNotFound
+	}
+
+	if !handle.hasTTL() {
+		return ErrNoTTL
+	}
+
+	handle.setExpiry(now.Add(ttl))
+	return nil
+}
+
+// InsertWithTTL adds a new document that expires ttl from now. Expired
+// documents are invisible to Get immediately and are physically removed by
+// the background sweeper shortly afterwards.
+func (s *Store) InsertWithTTL(doc map[string]any, ttl time.Duration) (string, error) {
+	defer s.observe(time.Now(), MetricsHook.OnInsert)
+
+	if err := s.ensureOpen(); err != nil {
+		return "", err
+	}
+
+	if doc == nil {
+		return "", ErrInvalidDocument
+	}
+
+	if ttl <= 0 {
+		return "", ErrInvalidTTL
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.validateDocument(doc); err != nil {
+		return "", err
+	}
+
+	docID := s.newID()
+	if s.idTaken(docID) {
+		return "", ErrIDExists
+	}
+
+	handle := s.insertLocked(docID, doc)
+	handle.setExpiry(time.Now().Add(ttl))
+	s.expiring[docID] = struct{}{}
+
+	return docID, nil
+}
+
+// copyExpiry carries a document's TTL over from src onto handle, which must
+// belong to s. The caller must hold s.mu for writing.
+func (s *Store) copyExpiry(src *Store, handle *DocumentHandle) {
+	source, exists := src.handles[handle.id]
+	if !exists || !source.handle.hasTTL() {
+		return
+	}
+
+	handle.setExpiry(source.handle.expiry())
+	s.expiring[handle.id] = struct{}{}
+}
+
+// sweepExpired periodically removes expired documents until the store is closed.
+func (s *Store) sweepExpired(interval time.Duration) {
+	defer close(s.sweepDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.sweepStop:
+			return
+		case now := <-ticker.C:
+			s.removeExpired(now)
+		}
+	}
+}
+
+// removeExpired deletes every document whose TTL has elapsed by now and
+// returns how many were removed.
+func (s *Store) removeExpired(now time.Time) int {
+	// Find candidates under the read lock so writers are only blocked when
+	// there is something to delete
+	s.mu.RLock()
+	var expired []string
+	for docID := range s.expiring {
+		if entry, exists := s.handles[docID]; exists && entry.handle.expired(now) {
+			expired = append(expired, docID)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(expired) == 0 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, docID := range expired {
+		// Re-check in case the document was refreshed in the meantime
+		entry, exists := s.handles[docID]
+		if !exists || !entry.handle.expired(now) {
+			continue
+		}
+		if s.deleteLocked(docID) == nil {
+			removed++
+		}
+	}
+
+	return removed
+}
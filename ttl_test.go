@@ -0,0 +1,296 @@
+package gostore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestInsertWithTTL tests that expired documents disappear from Get before
+// being swept and from the indexes once swept.
+func TestInsertWithTTL(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_kind", []string{"kind"})
+
+	id, err := s.InsertWithTTL(map[string]any{"kind": "session"}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("InsertWithTTL failed: %v", err)
+	}
+	permanent, _ := s.Insert(map[string]any{"kind": "session"})
+
+	if _, err := s.Get(id); err != nil {
+		t.Fatalf("Expected live document before expiry, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	// Expired but not yet swept
+	if _, err := s.Get(id); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound for expired document, got %v", err)
+	}
+
+	if removed := s.removeExpired(time.Now()); removed != 1 {
+		t.Errorf("Expected 1 expired document removed, got %d", removed)
+	}
+
+	results, _ := s.Lookup("by_kind", []any{"session"})
+	if len(results) != 1 || results[0].ID != permanent {
+		t.Errorf("Expected only the permanent document in the index, got %v", results)
+	}
+
+	if _, err := s.InsertWithTTL(map[string]any{}, 0); err != ErrInvalidTTL {
+		t.Errorf("Expected ErrInvalidTTL, got %v", err)
+	}
+	if _, err := s.InsertWithTTL(nil, time.Second); err != ErrInvalidDocument {
+		t.Errorf("Expected ErrInvalidDocument, got %v", err)
+	}
+}
+
+// TestTTLSweeper tests that the background sweeper removes expired documents
+// and stops when the store is closed.
+func TestTTLSweeper(t *testing.T) {
+	s := newStore(10 * time.Millisecond)
+
+	_, _ = s.InsertWithTTL(map[string]any{"n": 1}, 20*time.Millisecond)
+	_, _ = s.Insert(map[string]any{"n": 2})
+
+	deadline := time.Now().Add(time.Second)
+	for s.Count() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s.Count() != 1 {
+		t.Errorf("Expected sweeper to leave 1 document, got %d", s.Count())
+	}
+
+	s.Close()
+	select {
+	case <-s.sweepDone:
+	case <-time.After(time.Second):
+		t.Fatal("Sweeper did not stop after Close")
+	}
+}
+
+// TestRefresh tests that refreshing extends a document's life while
+// un-refreshed documents still expire on schedule.
+func TestRefresh(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	refreshed, _ := s.InsertWithTTL(map[string]any{"session": "a"}, 100*time.Millisecond)
+	stale, _ := s.InsertWithTTL(map[string]any{"session": "b"}, 100*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	if err := s.Refresh(refreshed, 300*time.Millisecond); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	doc, err := s.Get(refreshed)
+	if err != nil {
+		t.Fatalf("Expected refreshed document to be alive, got %v", err)
+	}
+	if doc.Version != 1 || doc.Data["session"] != "a" {
+		t.Errorf("Refresh changed the document: %+v", doc)
+	}
+
+	if _, err := s.Get(stale); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound for expired document, got %v", err)
+	}
+	if err := s.Refresh(stale, time.Second); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound refreshing expired document, got %v", err)
+	}
+}
+
+// TestRefreshConcurrentWithCompact tests that a refresh is not lost when
+// Compact replaces the document's handle at the same time.
+func TestRefreshConcurrentWithCompact(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	fillers := make([]string, 200)
+	for i := range fillers {
+		fillers[i], _ = s.Insert(map[string]any{"filler": i})
+	}
+	sessions := make([]string, 50)
+	for i := range sessions {
+		sessions[i], _ = s.InsertWithTTL(map[string]any{"session": i}, 50*time.Millisecond)
+	}
+
+	// Each delete leaves a hole below every session, so each Compact moves them all
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, id := range fillers {
+			_ = s.Delete(id)
+			_ = s.Compact()
+		}
+	}()
+
+	for range 20 {
+		for _, id := range sessions {
+			if err := s.Refresh(id, time.Second); err != nil {
+				t.Fatalf("Refresh failed: %v", err)
+			}
+		}
+	}
+	<-done
+
+	time.Sleep(60 * time.Millisecond)
+	for _, id := range sessions {
+		if _, err := s.Get(id); err != nil {
+			t.Fatalf("Expected refreshed document %s to be alive, got %v", id, err)
+		}
+	}
+}
+
+// TestRefreshErrors tests Refresh on documents that cannot be refreshed.
+func TestRefreshErrors(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	id, _ := s.Insert(map[string]any{"permanent": true})
+
+	if err := s.Refresh(id, time.Second); err != ErrNoTTL {
+		t.Errorf("Expected ErrNoTTL, got %v", err)
+	}
+	if err := s.Refresh("missing", time.Second); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+	if err := s.Refresh(id, 0); err != ErrInvalidTTL {
+		t.Errorf("Expected ErrInvalidTTL, got %v", err)
+	}
+
+	s.Close()
+	if err := s.Refresh(id, time.Second); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
+
+// TestExpiredHiddenBeforeSweep tests that every read path leaves out expired
+// documents the sweeper has not removed yet, as Get does.
+func TestExpiredHiddenBeforeSweep(t *testing.T) {
+	s := newStore(time.Hour) // Keep the sweeper out of the way
+	defer s.Close()
+
+	_ = s.CreateIndex("by_kind", []string{"kind"})
+
+	expired, _ := s.InsertWithTTL(map[string]any{"kind": "session", "name": "expired"}, 10*time.Millisecond)
+	permanent, _ := s.Insert(map[string]any{"kind": "session", "name": "permanent"})
+	time.Sleep(20 * time.Millisecond)
+
+	if _, pending := s.expiring[expired]; !pending {
+		t.Fatal("Expected the expired document to still await the sweeper")
+	}
+
+	only := func(name string, ids []string) {
+		t.Helper()
+		if len(ids) != 1 || ids[0] != permanent {
+			t.Errorf("%s: expected only the permanent document, got %v", name, ids)
+		}
+	}
+	resultIDs := func(results []*DocumentResult) []string {
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return ids
+	}
+	streamIDs := func(stream *DocumentStream) []string {
+		defer stream.Close()
+		var ids []string
+		for {
+			result, err := stream.Next()
+			if err != nil {
+				return ids
+			}
+			ids = append(ids, result.ID)
+		}
+	}
+	cursorIDs := func(cursor *StoreCursor[map[string]any]) []string {
+		defer cursor.Close()
+		byName := map[any]string{"expired": expired, "permanent": permanent}
+		var ids []string
+		for {
+			doc, _, err := cursor.Next()
+			if err != nil || doc == nil {
+				return ids
+			}
+			ids = append(ids, byName[(*doc)["name"]])
+		}
+	}
+
+	results, _ := s.Lookup("by_kind", []any{"session"})
+	only("Lookup", resultIDs(results))
+	results, _ = s.LookupRange("by_kind", []any{"a"}, []any{"z"})
+	only("LookupRange", resultIDs(results))
+	ids, _ := s.LookupIDs("by_kind", []any{"session"})
+	only("LookupIDs", ids)
+	ids, _ = s.LookupRangeIDs("by_kind", []any{"a"}, []any{"z"})
+	only("LookupRangeIDs", ids)
+	if count, _ := s.CountRange("by_kind", []any{"a"}, []any{"z"}); count != 1 {
+		t.Errorf("CountRange: expected 1, got %d", count)
+	}
+
+	only("Stream", streamIDs(s.Stream(0)))
+	only("StreamOrdered", streamIDs(s.StreamOrdered(0, "kind", true)))
+	stream, _ := s.StreamLookup("by_kind", []any{"session"}, 0)
+	only("StreamLookup", streamIDs(stream))
+
+	cursor, _ := s.Read()
+	only("Read", cursorIDs(cursor))
+	cursor, _ = s.ReadIndex("by_kind")
+	only("ReadIndex", cursorIDs(cursor))
+
+	if groups, _ := s.GroupBy("kind", "kind", AggregateCount); groups["session"] != 1 {
+		t.Errorf("GroupBy: expected a count of 1, got %v", groups)
+	}
+}
+
+// TestExpiredHiddenFromIndexReads tests that reads answered from an index
+// alone, and Count, leave out expired documents the sweeper has not removed
+// yet.
+func TestExpiredHiddenFromIndexReads(t *testing.T) {
+	s := newStore(time.Hour) // Keep the sweeper out of the way
+	defer s.Close()
+
+	_ = s.CreateIndex("by_score", []string{"score"})
+
+	_, _ = s.InsertWithTTL(map[string]any{"score": 1}, 10*time.Millisecond)
+	live, _ := s.Insert(map[string]any{"score": 2})
+	_, _ = s.InsertWithTTL(map[string]any{"score": 3}, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	if n := s.Count(); n != 1 {
+		t.Errorf("Count: expected 1, got %d", n)
+	}
+
+	for name, extreme := range map[string]func(string) (*DocumentResult, error){
+		"IndexMin": s.IndexMin,
+		"IndexMax": s.IndexMax,
+	} {
+		if doc, err := extreme("by_score"); err != nil || doc.ID != live {
+			t.Errorf("%s: expected the live document, got %v, %v", name, doc, err)
+		}
+	}
+
+	want := [][]any{{2}}
+	if keys, _ := s.DistinctValues("by_score"); !reflect.DeepEqual(keys, want) {
+		t.Errorf("DistinctValues: expected %v, got %v", want, keys)
+	}
+	if keys, _ := s.LookupCovered("by_score", []any{1}); len(keys) != 0 {
+		t.Errorf("LookupCovered: expected no keys for the expired document, got %v", keys)
+	}
+	if keys, _ := s.LookupRangeCovered("by_score", []any{0}, []any{10}); !reflect.DeepEqual(keys, want) {
+		t.Errorf("LookupRangeCovered: expected %v, got %v", want, keys)
+	}
+
+	if removed := s.removeExpired(time.Now()); removed != 2 {
+		t.Fatalf("Expected 2 expired documents removed, got %d", removed)
+	}
+	if n := s.Count(); n != 1 {
+		t.Errorf("Count after sweep: expected 1, got %d", n)
+	}
+}
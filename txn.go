@@ -0,0 +1,265 @@
+package gostore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// txnOpKind identifies a buffered transaction write.
+type txnOpKind int
+
+const (
+	txnInsert txnOpKind = iota
+	txnUpdate
+	txnDelete
+)
+
+// txnOp is a single buffered transaction write.
+type txnOp struct {
+	kind txnOpKind
+	id   string
+	data map[string]any // Nil for deletes
+}
+
+// StoreTxn buffers inserts, updates and deletes and applies them to the store
+// atomically on Commit. Reads through the transaction see its own buffered
+// writes layered over the store's current state; writes made to the store by
+// others become visible as they happen. A StoreTxn is not safe for concurrent
+// use.
+type StoreTxn struct {
+	store *Store
+	ops   []txnOp
+	done  bool
+}
+
+// Begin starts a transaction on the store.
+func (s *Store) Begin() *StoreTxn {
+	return &StoreTxn{store: s}
+}
+
+// Insert buffers a new document and returns the ID it will be stored under.
+func (tx *StoreTxn) Insert(doc map[string]any) (string, error) {
+	if tx.done {
+		return "", ErrTxnDone
+	}
+
+	if err := tx.store.ensureOpen(); err != nil {
+		return "", err
+	}
+
+	if doc == nil {
+		return "", ErrInvalidDocument
+	}
+
+	tx.store.mu.Lock()
+	docID := tx.store.newID()
+	tx.store.mu.Unlock()
+
+	tx.ops = append(tx.ops, txnOp{kind: txnInsert, id: docID, data: copyDocument(doc)})
+	return docID, nil
+}
+
+// Update buffers a replacement of a document's data.
+func (tx *StoreTxn) Update(docID string, doc map[string]any) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+
+	if err := tx.store.ensureOpen(); err != nil {
+		return err
+	}
+
+	if doc == nil {
+		return ErrInvalidDocument
+	}
+
+	tx.ops = append(tx.ops, txnOp{kind: txnUpdate, id: docID, data: copyDocument(doc)})
+	return nil
+}
+
+// Delete buffers the removal of a document.
+func (tx *StoreTxn) Delete(docID string) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+
+	if err := tx.store.ensureOpen(); err != nil {
+		return err
+	}
+
+	tx.ops = append(tx.ops, txnOp{kind: txnDelete, id: docID})
+	return nil
+}
+
+// Get retrieves a document, seeing the transaction's own buffered writes.
+// Documents written by the transaction report a zero version and timestamps
+// until it commits.
+func (tx *StoreTxn) Get(docID string) (*DocumentResult, error) {
+	if tx.done {
+		return nil, ErrTxnDone
+	}
+
+	if err := tx.store.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	for i := len(tx.ops) - 1; i >= 0; i-- {
+		op := tx.ops[i]
+		if op.id != docID {
+			continue
+		}
+		if op.kind == txnDelete {
+			return nil, ErrDocumentNotFound
+		}
+		return &DocumentResult{ID: docID, Data: op.data}, nil
+	}
+
+	return tx.store.Get(docID)
+}
+
+// CountByIndexRange returns how many documents in [minValues, maxValues) of an
+// index the transaction sees, like Store.CountRange. Documents the transaction
+// has written or deleted are counted by their buffered state, while writes
+// that other transactions have not yet committed are not counted, as they are
+// only buffered there. Documents the transaction hasn't touched are counted
+// straight from the index without being read.
+func (tx *StoreTxn) CountByIndexRange(indexName string, minValues, maxValues []any) (int, error) {
+	if tx.done {
+		return 0, ErrTxnDone
+	}
+
+	s := tx.store
+	if err := s.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	index, err := s.indexForQuery(context.Background(), indexName)
+	if err != nil {
+		return 0, err
+	}
+
+	// The latest buffered state of each document; nil if it is deleted
+	pending := make(map[string]map[string]any)
+	for _, op := range tx.ops {
+		pending[op.id] = op.data
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := s.countLive(index, minValues, maxValues)
+	now := time.Now()
+	for docID, data := range pending {
+		if handle, live := s.liveHandle(docID, now); live {
+			if doc, exists := s.collection.Get(handle.index); exists && index.keyInRange(doc.data, minValues, maxValues) {
+				count-- // Counted by the index under its committed data
+			}
+		}
+		if data != nil && index.keyInRange(data, minValues, maxValues) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Rollback discards the buffered writes. It doesn't touch the store, so it
+// also works once the store is closed.
+func (tx *StoreTxn) Rollback() error {
+	if tx.done {
+		return ErrTxnDone
+	}
+
+	tx.done = true
+	tx.ops = nil
+	return nil
+}
+
+// Commit applies the buffered writes with the store locked, so other readers
+// and writers see either all of them or none. Writes are first replayed
+// against the store's current state: inserting an ID that is taken fails with
+// ErrIDExists, updating or deleting a missing document with
+// ErrDocumentNotFound, and strict typed indexes may reject data with
+// ErrTypeMismatch. On failure nothing is written. Each document is written at
+// most once, with the net effect of the transaction's writes to it, so its
+// indexes are updated once. The transaction is finished either way.
+func (tx *StoreTxn) Commit() error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	tx.done = true
+
+	s := tx.store
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Replay the writes to find each document's final state
+	type netWrite struct {
+		existed bool
+		data    map[string]any // Nil if the document ends up deleted
+	}
+	writes := make(map[string]*netWrite)
+	var order []string
+
+	for _, op := range tx.ops {
+		write, seen := writes[op.id]
+		if !seen {
+			_, existed := s.handles[op.id]
+			write = &netWrite{existed: existed}
+			if existed {
+				if doc, exists := s.collection.Get(s.handles[op.id].handle.index); exists {
+					write.data = doc.data
+				}
+			}
+			writes[op.id] = write
+			order = append(order, op.id)
+		}
+
+		switch op.kind {
+		case txnInsert:
+			if write.data != nil || (!seen && s.idTaken(op.id)) {
+				return fmt.Errorf("document %s: %w", op.id, ErrIDExists)
+			}
+		case txnUpdate, txnDelete:
+			if write.data == nil {
+				return fmt.Errorf("document %s: %w", op.id, ErrDocumentNotFound)
+			}
+		}
+
+		if op.data != nil {
+			if err := s.validateDocument(op.data); err != nil {
+				return err
+			}
+		}
+		write.data = op.data
+	}
+
+	// Apply deletes and updates before inserts so that eviction triggered by
+	// an insert cannot remove a document this transaction still has to write
+	for _, docID := range order {
+		if write := writes[docID]; write.existed && write.data == nil {
+			if err := s.deleteLocked(docID); err != nil {
+				return err
+			}
+		}
+	}
+	for _, docID := range order {
+		if write := writes[docID]; write.existed && write.data != nil {
+			if err := s.updateLocked(docID, write.data); err != nil {
+				return err
+			}
+		}
+	}
+	for _, docID := range order {
+		if write := writes[docID]; !write.existed && write.data != nil {
+			s.insertLocked(docID, write.data)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package gostore
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestTxnCommit tests that buffered writes are invisible until commit and
+// then applied together.
+func TestTxnCommit(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_city", []string{"city"})
+	updateID, _ := s.Insert(map[string]any{"city": "NYC"})
+	deleteID, _ := s.Insert(map[string]any{"city": "LA"})
+
+	tx := s.Begin()
+	newID, err := tx.Insert(map[string]any{"city": "SF"})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	_ = tx.Update(updateID, map[string]any{"city": "Boston"})
+	_ = tx.Delete(deleteID)
+
+	// The transaction sees its own writes; the store does not yet
+	if doc, err := tx.Get(newID); err != nil || doc.Data["city"] != "SF" {
+		t.Errorf("Expected buffered insert to be visible in the transaction, got %v (%v)", doc, err)
+	}
+	if _, err := tx.Get(deleteID); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected buffered delete to hide the document, got %v", err)
+	}
+	if _, err := s.Get(newID); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected insert to be invisible before commit, got %v", err)
+	}
+	if doc, _ := s.Get(updateID); doc.Data["city"] != "NYC" {
+		t.Errorf("Expected update to be invisible before commit, got %v", doc.Data)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if doc, err := s.Get(newID); err != nil || doc.Data["city"] != "SF" {
+		t.Errorf("Expected committed insert, got %v (%v)", doc, err)
+	}
+	if results, _ := s.Lookup("by_city", []any{"Boston"}); len(results) != 1 || results[0].ID != updateID {
+		t.Errorf("Expected committed update to be indexed, got %v", results)
+	}
+	if _, err := s.Get(deleteID); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected committed delete, got %v", err)
+	}
+	if results, _ := s.Lookup("by_city", []any{"LA"}); len(results) != 0 {
+		t.Errorf("Expected deleted document to leave the index, got %v", results)
+	}
+
+	if err := tx.Commit(); !errors.Is(err, ErrTxnDone) {
+		t.Errorf("Expected ErrTxnDone on second commit, got %v", err)
+	}
+	if _, err := tx.Insert(map[string]any{}); !errors.Is(err, ErrTxnDone) {
+		t.Errorf("Expected ErrTxnDone after commit, got %v", err)
+	}
+}
+
+// TestTxnNetEffect tests that several writes to one document are collapsed
+// into a single write.
+func TestTxnNetEffect(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	events, cancel := s.Watch(16)
+	defer cancel()
+
+	existingID, _ := s.Insert(map[string]any{"n": 0})
+	<-events
+
+	tx := s.Begin()
+	_ = tx.Update(existingID, map[string]any{"n": 1})
+	_ = tx.Update(existingID, map[string]any{"n": 2})
+	tempID, _ := tx.Insert(map[string]any{"n": 3})
+	_ = tx.Delete(tempID)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if doc, _ := s.Get(existingID); doc.Data["n"] != 2 {
+		t.Errorf("Expected final update to win, got %v", doc.Data)
+	}
+	if _, err := s.Get(tempID); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected inserted-then-deleted document to be absent, got %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != ChangeUpdate || event.ID != existingID {
+			t.Errorf("Expected a single update event, got %+v", event)
+		}
+	default:
+		t.Fatal("Expected an update event")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Expected no further events, got %+v", event)
+	default:
+	}
+}
+
+// TestTxnAllOrNothing tests that a failing commit writes nothing.
+func TestTxnAllOrNothing(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	existingID, _ := s.Insert(map[string]any{"n": 0})
+
+	tx := s.Begin()
+	newID, _ := tx.Insert(map[string]any{"n": 1})
+	_ = tx.Update(existingID, map[string]any{"n": 2})
+	_ = tx.Delete("missing")
+	if err := tx.Commit(); !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("Expected ErrDocumentNotFound, got %v", err)
+	}
+
+	if _, err := s.Get(newID); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected insert to be discarded, got %v", err)
+	}
+	if doc, _ := s.Get(existingID); doc.Data["n"] != 0 {
+		t.Errorf("Expected update to be discarded, got %v", doc.Data)
+	}
+
+	// Conflicting with a concurrent writer also fails the whole commit
+	tx = s.Begin()
+	_ = tx.Update(existingID, map[string]any{"n": 3})
+	_ = s.Delete(existingID)
+	if err := tx.Commit(); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound for a concurrently deleted document, got %v", err)
+	}
+}
+
+// TestTxnRollback tests that rolled back writes are discarded.
+func TestTxnRollback(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	tx := s.Begin()
+	id, _ := tx.Insert(map[string]any{"n": 1})
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if _, err := s.Get(id); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected rolled back insert to be absent, got %v", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxnDone) {
+		t.Errorf("Expected ErrTxnDone after rollback, got %v", err)
+	}
+	if s.Count() != 0 {
+		t.Errorf("Expected empty store, got %d documents", s.Count())
+	}
+}
+
+// TestTxnCountByIndexRange tests that range counts see the transaction's own
+// writes but not another transaction's uncommitted ones.
+func TestTxnCountByIndexRange(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	_ = s.CreateIndex("by_score", []string{"score"})
+	var ids []string
+	for i := range 5 {
+		id, _ := s.Insert(map[string]any{"score": i * 10}) // 0, 10, 20, 30, 40
+		ids = append(ids, id)
+	}
+
+	tx := s.Begin()
+	other := s.Begin()
+
+	// The other transaction keeps writing into the range while the counts
+	// run, and its writes stay invisible until it commits
+	written := make(chan struct{})
+	stop := make(chan struct{})
+	inserted := make(chan int)
+	go func() {
+		_ = other.Delete(ids[1])
+		n := 0
+		for {
+			_, _ = other.Insert(map[string]any{"score": 15})
+			if n++; n == 1 {
+				close(written)
+			}
+			select {
+			case <-stop:
+				inserted <- n
+				return
+			default:
+			}
+		}
+	}()
+	<-written
+
+	_, _ = tx.Insert(map[string]any{"score": 25})            // Added in range
+	_ = tx.Update(ids[2], map[string]any{"score": 99})       // Moved out of range
+	_ = tx.Update(ids[0], map[string]any{"score": 12})       // Moved into range
+	_ = tx.Delete(ids[3])                                    // Removed from range
+	_, _ = tx.Insert(map[string]any{"score": "unindexable"}) // Outside the range
+
+	for range 100 {
+		if count, err := tx.CountByIndexRange("by_score", []any{10}, []any{40}); err != nil || count != 3 { // 10, 12 and 25
+			t.Errorf("Expected 3 documents in the transaction's view, got %d (err=%v)", count, err)
+			break
+		}
+		if count, _ := s.CountRange("by_score", []any{10}, []any{40}); count != 3 { // 10, 20, 30
+			t.Errorf("Expected the store to count 3 committed documents, got %d", count)
+			break
+		}
+	}
+
+	close(stop)
+	n := <-inserted
+	if err := other.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if count, _ := tx.CountByIndexRange("by_score", []any{10}, []any{40}); count != n+2 { // 12, 25 and 15 for each insert
+		t.Errorf("Expected %d documents once the other transaction commits, got %d", n+2, count)
+	}
+
+	if _, err := tx.CountByIndexRange("missing", nil, nil); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+	_ = tx.Rollback()
+	if _, err := tx.CountByIndexRange("by_score", nil, nil); !errors.Is(err, ErrTxnDone) {
+		t.Errorf("Expected ErrTxnDone, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package gostore
+
+// ChangeType identifies the kind of mutation reported by a ChangeEvent.
+type ChangeType int
+
+const (
+	// ChangeInsert reports a newly inserted document.
+	ChangeInsert ChangeType = iota
+	// ChangeUpdate reports a modified document.
+	ChangeUpdate
+	// ChangeDelete reports a removed document.
+	ChangeDelete
+)
+
+// String returns a readable name for the change type.
+func (ct ChangeType) String() string {
+	switch ct {
+	case ChangeInsert:
+		return "insert"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// ChangeEvent describes a single committed mutation. For deletes, Version is
+// the last version the document had before it was removed.
+type ChangeEvent struct {
+	Type    ChangeType
+	ID      string
+	Version uint64
+}
+
+// Watch subscribes to document changes. Events are delivered on the returned
+// channel in commit order; when the channel's buffer is full, events are
+// dropped for that watcher rather than blocking writers. The returned function
+// unsubscribes and closes the channel. All watcher channels are closed when the
+// store is closed.
+func (s *Store) Watch(bufferSize int) (<-chan ChangeEvent, func()) {
+	ch := make(chan ChangeEvent, max(bufferSize, 0))
+
+	if s == nil {
+		close(ch)
+		return ch, func() {}
+	}
+
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	// Checked under watchMu so a concurrent Close can't miss this watcher
+	if s.closed.Load() {
+		close(ch)
+		return ch, func() {}
+	}
+
+	id := s.nextWatch
+	s.nextWatch++
+	s.watchers[id] = ch
+
+	return ch, func() {
+		s.watchMu.Lock()
+		defer s.watchMu.Unlock()
+
+		if ch, exists := s.watchers[id]; exists {
+			delete(s.watchers, id)
+			close(ch)
+		}
+	}
+}
+
+// broadcast delivers an event to every watcher without blocking.
+func (s *Store) broadcast(event ChangeEvent) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	for _, ch := range s.watchers {
+		select {
+		case ch <- event:
+		default:
+			// Slow consumer; drop rather than stall the writer
+		}
+	}
+}
+
+// closeWatchers closes and forgets every watcher channel.
+func (s *Store) closeWatchers() {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	for id, ch := range s.watchers {
+		close(ch)
+		delete(s.watchers, id)
+	}
+}
@@ -0,0 +1,87 @@
+package gostore
+
+import (
+	"testing"
+	"time"
+)
+
+// TestWatch tests that inserts, updates and deletes are broadcast in order.
+func TestWatch(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	events, unsubscribe := s.Watch(10)
+	defer unsubscribe()
+
+	id, _ := s.Insert(map[string]any{"n": 1})
+	_ = s.Update(id, map[string]any{"n": 2})
+	_ = s.Delete(id)
+
+	expected := []ChangeEvent{
+		{Type: ChangeInsert, ID: id, Version: 1},
+		{Type: ChangeUpdate, ID: id, Version: 2},
+		{Type: ChangeDelete, ID: id, Version: 2},
+	}
+	for i, want := range expected {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("Event %d: expected %+v, got %+v", i, want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for event %d", i)
+		}
+	}
+}
+
+// TestWatchDropsForSlowConsumers tests that a full watcher never blocks writers.
+func TestWatchDropsForSlowConsumers(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	events, unsubscribe := s.Watch(2)
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		for i := range 10 {
+			_, _ = s.Insert(map[string]any{"n": i})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Writers blocked on a slow watcher")
+	}
+
+	if len(events) != 2 {
+		t.Errorf("Expected 2 buffered events, got %d", len(events))
+	}
+}
+
+// TestWatchUnsubscribeAndClose tests that watcher channels are closed on
+// unsubscribe and on store Close.
+func TestWatchUnsubscribeAndClose(t *testing.T) {
+	s := NewStore()
+
+	first, unsubscribe := s.Watch(1)
+	second, _ := s.Watch(1)
+
+	unsubscribe()
+	unsubscribe() // Safe to call twice
+	if _, ok := <-first; ok {
+		t.Error("Expected unsubscribed channel to be closed")
+	}
+
+	s.Close()
+	if _, ok := <-second; ok {
+		t.Error("Expected watcher channel to be closed by Close")
+	}
+
+	late, _ := s.Watch(1)
+	if _, ok := <-late; ok {
+		t.Error("Expected Watch on a closed store to return a closed channel")
+	}
+}